@@ -0,0 +1,91 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// NewAssumeRoleCredentialsProvider creates a provider of the credentials of the role assumed (by way of a
+// signed STS AssumeRole request) with the credentials specified by sourceOptions (or, without any, ambient
+// credentials). The Region and Endpoint options locate the STS service, and the ExternalID, RoleDuration,
+// and MFA options configure the request. The credentials are cached until they are about to expire.
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRole.html
+func NewAssumeRoleCredentialsProvider(roleARN, sessionName string, sourceOptions ...Option) CredentialsProvider {
+	return NewCachedCredentialsProvider(&assumeRoleCredentialsProvider{
+		roleARN:     roleARN,
+		sessionName: sessionName,
+		options:     sourceOptions,
+		client:      &http.Client{Timeout: time.Second * 5},
+	})
+}
+
+type assumeRoleCredentialsProvider struct {
+	roleARN     string
+	sessionName string
+	options     []Option
+	client      *http.Client
+}
+
+func (this *assumeRoleCredentialsProvider) Retrieve() (CredentialsValue, error) {
+	in := stsInput(this.options)
+	if len(in.credentials) == 0 && len(in.credentialErrors) == 0 {
+		AmbientCredentials()(in)
+	}
+	if err := in.validateCredentials(); err != nil {
+		return CredentialsValue{}, err
+	}
+
+	parameters, err := this.parameters(in)
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	request, err := newSTSRequest(in.stsEndpoint(), stsAssumeRole, parameters)
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	in.signSTSRequest(request)
+
+	credentials, err := doSTSRequest(this.client, request, stsAssumeRole)
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	credentials.Source = SourceAssumeRole
+	return newCredentialsValue(credentials), nil
+}
+
+func (this *assumeRoleCredentialsProvider) parameters(in *inputModel) (url.Values, error) {
+	sessionName := this.sessionName
+	if len(sessionName) == 0 {
+		sessionName = "s3-" + strconv.FormatInt(in.now.UnixNano(), 10)
+	}
+	parameters := url.Values{
+		"RoleArn":         {this.roleARN},
+		"RoleSessionName": {sessionName},
+	}
+	if len(in.externalID) > 0 {
+		parameters.Set("ExternalId", in.externalID)
+	}
+	if in.roleDuration > 0 {
+		parameters.Set("DurationSeconds", strconv.Itoa(int(in.roleDuration.Seconds())))
+	}
+	if len(in.mfaSerial) > 0 {
+		parameters.Set("SerialNumber", in.mfaSerial)
+		if in.mfaTokenCode == nil {
+			return nil, fmt.Errorf("no token code for MFA device %q", in.mfaSerial)
+		}
+		tokenCode, err := in.mfaTokenCode()
+		if err != nil {
+			return nil, fmt.Errorf("could not get token code for MFA device %q: %w", in.mfaSerial, err)
+		}
+		parameters.Set("TokenCode", tokenCode)
+	}
+	return parameters, nil
+}
+
+// IsExpired defers to the expiration of the credentials, which are always temporary.
+func (this *assumeRoleCredentialsProvider) IsExpired() bool { return false }
+
+const stsAssumeRole = "AssumeRole"
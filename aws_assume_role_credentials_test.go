@@ -0,0 +1,170 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestAssumeRoleCredentialsFixture(t *testing.T) {
+	gunit.Run(new(AssumeRoleCredentialsFixture), t)
+}
+
+type AssumeRoleCredentialsFixture struct {
+	*gunit.Fixture
+	server        *httptest.Server
+	now           time.Time
+	form          url.Values
+	authorization string
+	token         string
+	validRequest  bool
+	requests      int
+}
+
+func (this *AssumeRoleCredentialsFixture) Setup() {
+	this.now = time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	this.server = httptest.NewServer(http.HandlerFunc(this.serveSTS))
+}
+func (this *AssumeRoleCredentialsFixture) Teardown() {
+	this.server.Close()
+}
+
+func (this *AssumeRoleCredentialsFixture) serveSTS(response http.ResponseWriter, request *http.Request) {
+	this.requests++
+	this.authorization = request.Header.Get("Authorization")
+	this.token = request.Header.Get("X-Amz-Security-Token")
+	payload := readAndReplaceBody(request)
+	signed, _ := http.NewRequest(request.Method, request.URL.String(), nil)
+	signed.Header.Set("Host", request.Host)
+	for _, name := range []string{"Content-Type", "X-Amz-Content-Sha256", "X-Amz-Date", "X-Amz-Security-Token"} {
+		setHeader(signed, name, request.Header.Get(name))
+	}
+	expected := newV4Signer("sts", "us-east-1", hashSHA256(payload), signed, awsCredentials{
+		AccessKeyID:     "source-access",
+		SecretAccessKey: "source-secret",
+	}).calculateSignature()
+	this.validRequest = this.authorization == expected.task4_AuthorizationHeader &&
+		request.Header.Get("X-Amz-Content-Sha256") == hashSHA256(payload)
+
+	_ = request.ParseForm()
+	this.form = request.PostForm
+	_, _ = io.WriteString(response, assumeRoleResponse)
+}
+
+func (this *AssumeRoleCredentialsFixture) retrieve(options ...Option) (CredentialsValue, error) {
+	options = append([]Option{Endpoint(this.server.URL), Region("us-east-1"), Timestamp(this.now)}, options...)
+	return NewAssumeRoleCredentialsProvider("arn:aws:iam::210987654321:role/cross-account", "session", options...).Retrieve()
+}
+
+func (this *AssumeRoleCredentialsFixture) TestAssumeRole() {
+	value, err := this.retrieve(Credentials("source-access", "source-secret"))
+
+	this.So(err, should.BeNil)
+	this.So(this.validRequest, should.BeTrue)
+	this.So(this.authorization, should.StartWith, "AWS4-HMAC-SHA256 Credential=source-access/20300101/us-east-1/sts/aws4_request, ")
+	this.So(this.form.Get("Action"), should.Equal, "AssumeRole")
+	this.So(this.form.Get("Version"), should.Equal, "2011-06-15")
+	this.So(this.form.Get("RoleArn"), should.Equal, "arn:aws:iam::210987654321:role/cross-account")
+	this.So(this.form.Get("RoleSessionName"), should.Equal, "session")
+	this.So(this.form, should.NotContainKey, "ExternalId")
+	this.So(this.form, should.NotContainKey, "DurationSeconds")
+	this.So(this.form, should.NotContainKey, "SerialNumber")
+	this.So(value, should.Resemble, CredentialsValue{
+		AccessKeyID:     "ASIAASSUMEDROLE",
+		SecretAccessKey: "assumed-secret",
+		SessionToken:    "assumed-token",
+		Expiration:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:          SourceAssumeRole,
+	})
+}
+
+func (this *AssumeRoleCredentialsFixture) TestSourceSessionTokenIsSigned() {
+	_, err := this.retrieve(STSCredentials("source-access", "source-secret", "source-token", this.now.Add(time.Hour)))
+
+	this.So(err, should.BeNil)
+	this.So(this.validRequest, should.BeTrue)
+	this.So(this.token, should.Equal, "source-token")
+	this.So(this.authorization, should.ContainSubstring, "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+}
+
+func (this *AssumeRoleCredentialsFixture) TestRegionalSigning() {
+	_, err := this.retrieve(Credentials("source-access", "source-secret"), Region("eu-west-1"))
+
+	this.So(err, should.BeNil)
+	this.So(this.authorization, should.ContainSubstring, "/20300101/eu-west-1/sts/aws4_request")
+}
+
+func (this *AssumeRoleCredentialsFixture) TestExternalIDDurationAndMFA() {
+	_, err := this.retrieve(
+		Credentials("source-access", "source-secret"),
+		ExternalID("external"),
+		RoleDuration(time.Hour*2),
+		MFA("arn:aws:iam::123456789012:mfa/user", func() (string, error) { return "123456", nil }),
+	)
+
+	this.So(err, should.BeNil)
+	this.So(this.validRequest, should.BeTrue)
+	this.So(this.form.Get("ExternalId"), should.Equal, "external")
+	this.So(this.form.Get("DurationSeconds"), should.Equal, "7200")
+	this.So(this.form.Get("SerialNumber"), should.Equal, "arn:aws:iam::123456789012:mfa/user")
+	this.So(this.form.Get("TokenCode"), should.Equal, "123456")
+}
+
+func (this *AssumeRoleCredentialsFixture) TestMFATokenCodeFailure() {
+	failure := errors.New("failure")
+	_, err := this.retrieve(
+		Credentials("source-access", "source-secret"),
+		MFA("serial", func() (string, error) { return "", failure }),
+	)
+
+	this.So(errors.Is(err, failure), should.BeTrue)
+	this.So(this.requests, should.Equal, 0)
+}
+
+func (this *AssumeRoleCredentialsFixture) TestSourceCredentialsFailure() {
+	failure := errors.New("failure")
+	_, err := this.retrieve(CredentialsFrom(&FakeCredentialsProvider{err: failure}))
+
+	this.So(errors.Is(err, ErrCredentialsMissing), should.BeTrue)
+	this.So(errors.Is(err, failure), should.BeTrue)
+	this.So(this.requests, should.Equal, 0)
+}
+
+func (this *AssumeRoleCredentialsFixture) TestCredentialsAreCached() {
+	option := AssumeRoleCredentials("role", "session", Endpoint(this.server.URL), Credentials("source-access", "source-secret"))
+
+	request1, err1 := NewRequest(GET, Region("us-east-1"), Bucket("bucket"), Key("key"), option)
+	request2, err2 := NewRequest(GET, Region("us-east-1"), Bucket("bucket"), Key("key"), option)
+
+	this.So(err1, should.BeNil)
+	this.So(err2, should.BeNil)
+	this.So(request1.Header.Get("Authorization"), should.ContainSubstring, "Credential=ASIAASSUMEDROLE/")
+	this.So(request2.Header.Get("X-Amz-Security-Token"), should.Equal, "assumed-token")
+	this.So(request1.URL.Host, should.Equal, "s3.amazonaws.com")
+	this.So(this.requests, should.Equal, 1)
+}
+
+const assumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::210987654321:assumed-role/cross-account/session</Arn>
+      <AssumedRoleId>ARO123EXAMPLE123:session</AssumedRoleId>
+    </AssumedRoleUser>
+    <Credentials>
+      <AccessKeyId>ASIAASSUMEDROLE</AccessKeyId>
+      <SecretAccessKey>assumed-secret</SecretAccessKey>
+      <SessionToken>assumed-token</SessionToken>
+      <Expiration>2030-01-02T03:04:05Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+  <ResponseMetadata>
+    <RequestId>request-id</RequestId>
+  </ResponseMetadata>
+</AssumeRoleResponse>`
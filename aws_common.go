@@ -8,6 +8,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -49,6 +50,19 @@ func readAndReplaceBody(request *http.Request) []byte {
 	return payload
 }
 
+func remainingLength(seeker io.Seeker) (int64, error) {
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	_, err = seeker.Seek(current, io.SeekStart)
+	return end - current, err
+}
+
 func setHeader(request *http.Request, key, value string) {
 	if len(value) > 0 || (len(value) > 0 && value != "0") {
 		request.Header.Set(key, value)
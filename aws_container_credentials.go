@@ -0,0 +1,148 @@
+package s3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// NewContainerCredentialsProvider creates a provider of the credentials served to ECS tasks
+// and EKS pods (Pod Identity) by the container credentials endpoint, which is configured by
+// $AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or $AWS_CONTAINER_CREDENTIALS_FULL_URI (and optionally
+// $AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE or $AWS_CONTAINER_AUTHORIZATION_TOKEN). The credentials
+// are cached until they are about to expire.
+func NewContainerCredentialsProvider() CredentialsProvider {
+	return NewCachedCredentialsProvider(newContainerCredentialsProvider(defaultContainerCredentialsHost))
+}
+
+type containerCredentialsProvider struct {
+	relativeHost string
+	client       *http.Client
+}
+
+func newContainerCredentialsProvider(relativeHost string) *containerCredentialsProvider {
+	return &containerCredentialsProvider{
+		relativeHost: relativeHost,
+		client:       &http.Client{Timeout: time.Second * 5},
+	}
+}
+
+func (this *containerCredentialsProvider) Retrieve() (CredentialsValue, error) {
+	address, err := this.address()
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	request, err := http.NewRequest(http.MethodGet, address, nil)
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	if token, err := containerAuthorizationToken(); err != nil {
+		return CredentialsValue{}, err
+	} else if len(token) > 0 {
+		request.Header.Set("Authorization", token)
+	}
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	defer closeHandle(response.Body)
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return CredentialsValue{}, fmt.Errorf("container credentials endpoint responded with %d %s: %s",
+			response.StatusCode, http.StatusText(response.StatusCode), strings.TrimSpace(string(body)))
+	}
+
+	var credentials awsCredentials
+	if err = json.Unmarshal(body, &credentials); err != nil {
+		return CredentialsValue{}, fmt.Errorf("malformed container credentials: %w", err)
+	}
+	if !credentials.complete() {
+		return CredentialsValue{}, errors.New("container credentials endpoint returned incomplete credentials")
+	}
+	credentials.Source = SourceContainer
+	return newCredentialsValue(credentials), nil
+}
+
+// IsExpired defers to the expiration of the credentials, which are always temporary.
+func (this *containerCredentialsProvider) IsExpired() bool { return false }
+
+// address prefers the relative URI (always served by the ECS agent), and otherwise requires
+// the full URI to either be secure or to be served locally, as do the AWS SDKs.
+func (this *containerCredentialsProvider) address() (string, error) {
+	if relative := os.Getenv(envContainerCredentialsRelativeURI); len(relative) > 0 {
+		return this.relativeHost + relative, nil
+	}
+
+	full := os.Getenv(envContainerCredentialsFullURI)
+	if len(full) == 0 {
+		return "", errContainerCredentialsNotConfigured
+	}
+	parsed, err := url.Parse(full)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", envContainerCredentialsFullURI, err)
+	}
+	if parsed.Scheme != "https" && !allowedContainerCredentialsHost(parsed.Hostname()) {
+		return "", fmt.Errorf("invalid %s: %q must use https or a loopback or container host", envContainerCredentialsFullURI, full)
+	}
+	return full, nil
+}
+
+func allowedContainerCredentialsHost(host string) bool {
+	switch host {
+	case "localhost", "169.254.170.2", "169.254.170.23", "fd00:ec2::23":
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// containerAuthorizationToken prefers the token file (which may be rotated) over the static token.
+func containerAuthorizationToken() (string, error) {
+	if filename := os.Getenv(envContainerAuthorizationTokenFile); len(filename) > 0 {
+		token, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("could not read container authorization token: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+	return os.Getenv(envContainerAuthorizationToken), nil
+}
+
+// containerCredentialsConfigured indicates whether the container credentials endpoint is configured.
+func containerCredentialsConfigured() bool {
+	return len(os.Getenv(envContainerCredentialsRelativeURI)) > 0 ||
+		len(os.Getenv(envContainerCredentialsFullURI)) > 0
+}
+
+// sharedContainerCredentials supplies a single (cached) provider for each configuration of the container
+// credentials endpoint (and authorization token) in the environment.
+func sharedContainerCredentials() CredentialsProvider {
+	key := strings.Join([]string{SourceContainer,
+		os.Getenv(envContainerCredentialsRelativeURI), os.Getenv(envContainerCredentialsFullURI),
+		os.Getenv(envContainerAuthorizationTokenFile), os.Getenv(envContainerAuthorizationToken),
+	}, "\n")
+	return sharedCredentials(key, NewContainerCredentialsProvider)
+}
+
+var errContainerCredentialsNotConfigured = errors.New("container credentials endpoint not configured")
+
+const (
+	envContainerCredentialsRelativeURI = "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"
+	envContainerCredentialsFullURI     = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+	envContainerAuthorizationToken     = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+	envContainerAuthorizationTokenFile = "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"
+
+	defaultContainerCredentialsHost = "http://169.254.170.2"
+)
@@ -0,0 +1,163 @@
+package s3
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestContainerCredentialsFixture(t *testing.T) {
+	gunit.Run(new(ContainerCredentialsFixture), t, gunit.Options.AllSequential())
+}
+
+type ContainerCredentialsFixture struct {
+	*gunit.Fixture
+	environment   *TemporaryEnvironment
+	server        *httptest.Server
+	provider      *containerCredentialsProvider
+	folder        string
+	path          string
+	authorization string
+	status        int
+}
+
+func (this *ContainerCredentialsFixture) Setup() {
+	this.folder, _ = ioutil.TempDir("", "s3-container-credentials")
+	this.environment = NewTemporaryEnvironment(
+		envAccessKeyID, envAccessKey, envSecretAccessKey, envSecretKey, envSecurityToken,
+		envProfile, envSharedCredentialsFile, envConfigFile,
+		envContainerCredentialsRelativeURI, envContainerCredentialsFullURI,
+		envRoleARN, envWebIdentityTokenFile,
+		envContainerAuthorizationToken, envContainerAuthorizationTokenFile,
+	)
+	this.environment.Set(envSharedCredentialsFile, filepath.Join(this.folder, "missing"))
+	this.environment.Set(envConfigFile, filepath.Join(this.folder, "missing"))
+
+	this.status = http.StatusOK
+	this.server = httptest.NewServer(http.HandlerFunc(this.serveCredentials))
+	this.provider = newContainerCredentialsProvider(this.server.URL)
+}
+func (this *ContainerCredentialsFixture) Teardown() {
+	this.server.Close()
+	this.environment.Restore()
+	_ = os.RemoveAll(this.folder)
+}
+
+func (this *ContainerCredentialsFixture) serveCredentials(response http.ResponseWriter, request *http.Request) {
+	this.path = request.URL.Path
+	this.authorization = request.Header.Get("Authorization")
+	response.WriteHeader(this.status)
+	if this.status != http.StatusOK {
+		_, _ = io.WriteString(response, "denied")
+		return
+	}
+	_, _ = io.WriteString(response, `{
+  "AccessKeyId": "container-access",
+  "Expiration": "2030-01-02T03:04:05Z",
+  "RoleArn": "arn:aws:iam::123456789012:role/task",
+  "SecretAccessKey": "container-secret",
+  "Token": "container-token"
+}`)
+}
+
+func (this *ContainerCredentialsFixture) TestRelativeURI() {
+	this.environment.Set(envContainerCredentialsRelativeURI, "/v2/credentials/task")
+
+	value, err := this.provider.Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(this.path, should.Equal, "/v2/credentials/task")
+	this.So(this.authorization, should.BeBlank)
+	this.So(value.AccessKeyID, should.Equal, "container-access")
+	this.So(value.SecretAccessKey, should.Equal, "container-secret")
+	this.So(value.SessionToken, should.Equal, "container-token")
+	this.So(value.Expiration.IsZero(), should.BeFalse)
+}
+
+func (this *ContainerCredentialsFixture) TestFullURIWithAuthorizationToken() {
+	this.environment.Set(envContainerCredentialsFullURI, this.server.URL+"/v1/credentials")
+	this.environment.Set(envContainerAuthorizationToken, "static-token")
+
+	value, err := this.provider.Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(this.path, should.Equal, "/v1/credentials")
+	this.So(this.authorization, should.Equal, "static-token")
+	this.So(value.AccessKeyID, should.Equal, "container-access")
+}
+
+func (this *ContainerCredentialsFixture) TestAuthorizationTokenFileTakesPrecedence() {
+	filename := filepath.Join(this.folder, "token")
+	_ = ioutil.WriteFile(filename, []byte("file-token\n"), 0600)
+	this.environment.Set(envContainerCredentialsFullURI, this.server.URL+"/v1/credentials")
+	this.environment.Set(envContainerAuthorizationToken, "static-token")
+	this.environment.Set(envContainerAuthorizationTokenFile, filename)
+
+	_, err := this.provider.Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(this.authorization, should.Equal, "file-token")
+}
+
+func (this *ContainerCredentialsFixture) TestMissingAuthorizationTokenFile() {
+	this.environment.Set(envContainerCredentialsFullURI, this.server.URL+"/v1/credentials")
+	this.environment.Set(envContainerAuthorizationTokenFile, filepath.Join(this.folder, "missing"))
+
+	_, err := this.provider.Retrieve()
+
+	this.So(err, should.NotBeNil)
+	this.So(this.path, should.BeBlank)
+}
+
+func (this *ContainerCredentialsFixture) TestInsecureRemoteFullURIIsRejected() {
+	this.environment.Set(envContainerCredentialsFullURI, "http://example.com/credentials")
+
+	_, err := this.provider.Retrieve()
+
+	this.So(err, should.NotBeNil)
+}
+
+func (this *ContainerCredentialsFixture) TestNotConfigured() {
+	_, err := this.provider.Retrieve()
+
+	this.So(err, should.Equal, errContainerCredentialsNotConfigured)
+}
+
+func (this *ContainerCredentialsFixture) TestEndpointFailure() {
+	this.status = http.StatusForbidden
+	this.environment.Set(envContainerCredentialsRelativeURI, "/v2/credentials/task")
+
+	_, err := this.provider.Retrieve()
+
+	this.So(err, should.NotBeNil)
+	this.So(err.Error(), should.ContainSubstring, "denied")
+}
+
+func (this *ContainerCredentialsFixture) TestAmbientCredentialsFromContainer() {
+	this.environment.Set(envContainerCredentialsFullURI, this.server.URL+"/v1/credentials")
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=container-access/")
+	this.So(request.Header.Get("X-Amz-Security-Token"), should.Equal, "container-token")
+}
+
+func (this *ContainerCredentialsFixture) TestAmbientCredentialsFollowReconfiguredEndpoint() {
+	this.environment.Set(envContainerCredentialsFullURI, this.server.URL+"/v1/credentials")
+	_, _ = NewRequest(GET, Bucket("bucket"), Key("key"))
+	this.environment.Set(envContainerCredentialsFullURI, this.server.URL+"/v2/credentials")
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), ContainerCredentials())
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=container-access/")
+	this.So(this.path, should.Equal, "/v2/credentials")
+}
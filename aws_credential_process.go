@@ -0,0 +1,84 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// NewProcessCredentialsProvider creates a provider of the credentials written (as JSON) to standard output
+// by the command, as with the credential_process setting of the shared config file. The command is run by
+// the shell and the credentials are cached until they are about to expire, at which point it is run again.
+// A command that doesn't finish within a minute is killed (and its credentials are unavailable).
+// https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-sourcing-external.html
+func NewProcessCredentialsProvider(command string) CredentialsProvider {
+	return NewCachedCredentialsProvider(&processCredentialsProvider{command: command, timeout: defaultCredentialProcessTimeout})
+}
+
+type processCredentialsProvider struct {
+	command string
+	timeout time.Duration
+}
+
+func (this *processCredentialsProvider) Retrieve() (CredentialsValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	command := shellCommand(ctx, this.command)
+	command.Stdout, command.Stderr = stdout, stderr
+	command.WaitDelay = time.Second // don't wait on any (orphaned) children still holding the output open
+	if err := command.Run(); ctx.Err() == context.DeadlineExceeded {
+		return CredentialsValue{}, fmt.Errorf("credential process %q failed: %w after %s", this.command, errCredentialProcessTimeout, this.timeout)
+	} else if err != nil {
+		return CredentialsValue{}, fmt.Errorf("credential process %q failed: %w: %s", this.command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output struct {
+		Version int
+		awsCredentials
+		SessionToken string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return CredentialsValue{}, fmt.Errorf("credential process %q produced malformed output: %w", this.command, err)
+	}
+	if output.Version != 1 {
+		return CredentialsValue{}, fmt.Errorf("credential process %q produced unsupported version %d", this.command, output.Version)
+	}
+	if len(output.SessionToken) > 0 {
+		output.SecurityToken = output.SessionToken
+	}
+	if !output.complete() {
+		return CredentialsValue{}, fmt.Errorf("credential process %q produced no AccessKeyId and SecretAccessKey", this.command)
+	}
+	output.Source = SourceProcess
+	return newCredentialsValue(output.awsCredentials), nil
+}
+
+// IsExpired defers to the expiration of the credentials (if any).
+func (this *processCredentialsProvider) IsExpired() bool { return false }
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// sharedProcessCredentials supplies a single (cached) provider for each command so that commands
+// configured in the shared config file aren't run for every request.
+func sharedProcessCredentials(command string) CredentialsProvider {
+	return sharedCredentials(SourceProcess+"\n"+command, func() CredentialsProvider {
+		return NewProcessCredentialsProvider(command)
+	})
+}
+
+var errCredentialProcessTimeout = errors.New("timed out")
+
+const defaultCredentialProcessTimeout = time.Minute
@@ -0,0 +1,183 @@
+package s3
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestCredentialProcessFixture(t *testing.T) {
+	gunit.Run(new(CredentialProcessFixture), t, gunit.Options.AllSequential())
+}
+
+type CredentialProcessFixture struct {
+	*gunit.Fixture
+	environment *TemporaryEnvironment
+	folder      string
+	output      string
+	invocations string
+	command     string
+}
+
+func (this *CredentialProcessFixture) Setup() {
+	this.folder, _ = ioutil.TempDir("", "s3-credential-process")
+	this.output = filepath.Join(this.folder, "output.json")
+	this.invocations = filepath.Join(this.folder, "invocations")
+	this.command = "echo invoked >> '" + this.invocations + "' && cat '" + this.output + "'"
+	this.writeOutput(`{
+  "Version": 1,
+  "AccessKeyId": "process-access",
+  "SecretAccessKey": "process-secret",
+  "SessionToken": "process-token",
+  "Expiration": "2030-01-02T03:04:05Z"
+}`)
+	this.environment = NewTemporaryEnvironment(
+		envAccessKeyID, envAccessKey, envSecretAccessKey, envSecretKey, envSecurityToken,
+		envProfile, envSharedCredentialsFile, envConfigFile,
+		envContainerCredentialsRelativeURI, envContainerCredentialsFullURI,
+		envRoleARN, envWebIdentityTokenFile, envRegion, envDefaultRegion,
+	)
+	this.environment.Set(envSharedCredentialsFile, filepath.Join(this.folder, "missing"))
+	this.environment.Set(envConfigFile, filepath.Join(this.folder, "missing"))
+}
+func (this *CredentialProcessFixture) Teardown() {
+	this.environment.Restore()
+	_ = os.RemoveAll(this.folder)
+}
+
+func (this *CredentialProcessFixture) writeOutput(content string) {
+	_ = ioutil.WriteFile(this.output, []byte(content), 0600)
+}
+func (this *CredentialProcessFixture) countInvocations() int {
+	content, _ := ioutil.ReadFile(this.invocations)
+	return strings.Count(string(content), "invoked")
+}
+
+func (this *CredentialProcessFixture) TestProcessCredentials() {
+	value, err := NewProcessCredentialsProvider(this.command).Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(value, should.Resemble, CredentialsValue{
+		AccessKeyID:     "process-access",
+		SecretAccessKey: "process-secret",
+		SessionToken:    "process-token",
+		Expiration:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:          SourceProcess,
+	})
+}
+
+func (this *CredentialProcessFixture) TestCredentialsWithoutExpirationAreCached() {
+	this.writeOutput(`{"Version": 1, "AccessKeyId": "process-access", "SecretAccessKey": "process-secret"}`)
+	provider := NewProcessCredentialsProvider(this.command)
+
+	value, err := provider.Retrieve()
+	_, _ = provider.Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(value.Expiration.IsZero(), should.BeTrue)
+	this.So(value.SessionToken, should.BeBlank)
+	this.So(this.countInvocations(), should.Equal, 1)
+}
+
+func (this *CredentialProcessFixture) TestExpiringCredentialsAreRefreshed() {
+	this.writeOutput(`{"Version": 1, "AccessKeyId": "process-access", "SecretAccessKey": "process-secret", "Expiration": "` +
+		time.Now().Add(time.Minute).UTC().Format(time.RFC3339) + `"}`)
+	provider := NewProcessCredentialsProvider(this.command)
+
+	_, _ = provider.Retrieve()
+	_, _ = provider.Retrieve()
+
+	this.So(this.countInvocations(), should.Equal, 2)
+}
+
+func (this *CredentialProcessFixture) TestProcessFailure() {
+	_, err := NewProcessCredentialsProvider("echo 'helper is locked' >&2; exit 3").Retrieve()
+
+	this.So(err, should.NotBeNil)
+	this.So(err.Error(), should.ContainSubstring, "exit status 3")
+	this.So(err.Error(), should.ContainSubstring, "helper is locked")
+}
+
+func (this *CredentialProcessFixture) TestProcessTimeout() {
+	provider := &processCredentialsProvider{command: "exec sleep 10", timeout: time.Millisecond * 100}
+
+	started := time.Now()
+	_, err := provider.Retrieve()
+
+	this.So(errors.Is(err, errCredentialProcessTimeout), should.BeTrue)
+	this.So(err.Error(), should.ContainSubstring, "timed out after 100ms")
+	this.So(time.Since(started), should.BeLessThan, time.Second*5)
+}
+
+func (this *CredentialProcessFixture) TestMalformedOutput() {
+	this.writeOutput("not json")
+	_, err := NewProcessCredentialsProvider(this.command).Retrieve()
+	this.So(err, should.NotBeNil)
+}
+
+func (this *CredentialProcessFixture) TestUnsupportedVersion() {
+	this.writeOutput(`{"Version": 2, "AccessKeyId": "process-access", "SecretAccessKey": "process-secret"}`)
+	_, err := NewProcessCredentialsProvider(this.command).Retrieve()
+	this.So(err, should.NotBeNil)
+	this.So(err.Error(), should.ContainSubstring, "version 2")
+}
+
+func (this *CredentialProcessFixture) TestIncompleteCredentials() {
+	this.writeOutput(`{"Version": 1, "AccessKeyId": "process-access"}`)
+	_, err := NewProcessCredentialsProvider(this.command).Retrieve()
+	this.So(err, should.NotBeNil)
+}
+
+func (this *CredentialProcessFixture) TestProcessCredentialsOption() {
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), ProcessCredentials(this.command))
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=process-access/")
+	this.So(request.Header.Get("X-Amz-Security-Token"), should.Equal, "process-token")
+}
+
+func (this *CredentialProcessFixture) TestProfileWithCredentialProcess() {
+	this.writeConfig("[profile helper]\ncredential_process = " + this.command + "\nregion = eu-central-1\n")
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), ProfileCredentials("helper"))
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=process-access/")
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/eu-central-1/s3/")
+}
+
+func (this *CredentialProcessFixture) TestProfileWithFailingCredentialProcess() {
+	this.writeConfig("[profile helper]\ncredential_process = exit 1\n")
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), ProfileCredentials("helper"))
+
+	this.So(request, should.BeNil)
+	this.So(err, should.NotBeNil)
+	this.So(err.Error(), should.ContainSubstring, "credential process")
+}
+
+func (this *CredentialProcessFixture) TestAmbientCredentialsFromCredentialProcess() {
+	this.writeConfig("[default]\ncredential_process = " + this.command + "\n")
+
+	request1, err1 := NewRequest(GET, Bucket("bucket"), Key("key"))
+	request2, err2 := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(err1, should.BeNil)
+	this.So(err2, should.BeNil)
+	this.So(request1.Header.Get("Authorization"), should.ContainSubstring, "Credential=process-access/")
+	this.So(request2.Header.Get("Authorization"), should.ContainSubstring, "Credential=process-access/")
+	this.So(this.countInvocations(), should.Equal, 1)
+}
+
+func (this *CredentialProcessFixture) writeConfig(content string) {
+	filename := filepath.Join(this.folder, "config")
+	_ = ioutil.WriteFile(filename, []byte(content), 0600)
+	this.environment.Set(envConfigFile, filename)
+}
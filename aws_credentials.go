@@ -1,13 +1,12 @@
 package s3
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net"
-	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +15,12 @@ type awsCredentials struct {
 	SecretAccessKey string
 	SecurityToken   string `json:"Token"`
 	Expiration      time.Time
+	Source          string `json:"-"`
+}
+
+// complete indicates whether both parts of the key pair are present.
+func (this *awsCredentials) complete() bool {
+	return len(this.AccessKeyID) > 0 && len(this.SecretAccessKey) > 0
 }
 
 // expired checks to see if the temporary credentials from an IAM role are
@@ -37,33 +42,81 @@ func (this *awsCredentials) expired() bool {
 	}
 }
 
+// These values of CredentialsValue.Source identify the option (or provider) that supplied the credentials.
+const (
+	SourceStatic      = "Credentials"
+	SourceSTS         = "STSCredentials"
+	SourceEnvironment = "EnvironmentCredentials"
+	SourceProfile     = "ProfileCredentials"
+	SourceProcess     = "ProcessCredentials"
+	SourceWebIdentity = "WebIdentityCredentials"
+	SourceAssumeRole  = "AssumeRoleCredentials"
+	SourceContainer   = "ContainerCredentials"
+	SourceIAMRole     = "IAMRoleCredentials"
+	SourceProvider    = "CredentialsFrom"
+)
+
+var (
+	errEnvironmentCredentialsMissing = errors.New("environment variables " + envAccessKeyID + " and " + envSecretAccessKey + " not set")
+	errNotOnEC2                      = errors.New("EC2 instance metadata service unreachable")
+)
+
 const (
 	envAccessKey       = "AWS_ACCESS_KEY"
 	envAccessKeyID     = "AWS_ACCESS_KEY_ID"
 	envSecretKey       = "AWS_SECRET_KEY"
 	envSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
 	envSecurityToken   = "AWS_SECURITY_TOKEN"
-)
+	envSessionToken    = "AWS_SESSION_TOKEN"
 
-// ambientCredentials produces a set of credentials based on the environment
-func ambientCredentials() awsCredentials {
-	// First use credentials from environment variables
-	newCredentials := loadCredentialsFromEnvironment()
+	envCredentialExpiration = "AWS_CREDENTIAL_EXPIRATION"
 
-	// If there is no Access Key and you are on EC2, get the key from the role
-	if (newCredentials.AccessKeyID == "" || newCredentials.SecretAccessKey == "") && onEC2() {
-		newCredentials = getIAMRoleCredentials()
-	}
+	envRegion        = "AWS_REGION"
+	envDefaultRegion = "AWS_DEFAULT_REGION"
+)
 
-	// If the key is expiring, get a new key
-	if newCredentials.expired() && onEC2() {
-		newCredentials = getIAMRoleCredentials()
+// ambientCredentials produces a set of credentials based on the environment,
+// or an error that describes why each potential source didn't supply them.
+func ambientCredentials() (credentials awsCredentials, region string, err error) {
+	var fallback awsCredentials
+	var fallbackRegion string
+	var failures []error
+	for _, resolve := range []func() (awsCredentials, string, error){
+		withoutRegion(loadCredentialsFromEnvironment), // First use credentials from environment variables
+		withoutRegion(loadWebIdentityCredentials),     // Then use the credentials of the role assumed with the web identity token (IRSA)
+		loadSharedProfileCredentials,                  // Then use the credentials (and region) of the profile in the shared credentials and config files
+		withoutRegion(loadContainerCredentials),       // Then use the credentials served to the ECS task or EKS pod
+		withoutRegion(loadIAMRoleCredentials),         // Then use the credentials of the role of the EC2 instance
+	} {
+		credentials, region, err = resolve()
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		if !credentials.complete() {
+			continue
+		}
+		if !credentials.expired() {
+			return credentials, region, nil
+		}
+		if !fallback.complete() {
+			fallback, fallbackRegion = credentials, region // If the key is expiring, look for a new key (but use this one otherwise)
+		}
+	}
+	if fallback.complete() {
+		return fallback, fallbackRegion, nil
 	}
+	return awsCredentials{}, "", errors.Join(failures...)
+}
 
-	return newCredentials
+func withoutRegion(load func() (awsCredentials, error)) func() (awsCredentials, string, error) {
+	return func() (awsCredentials, string, error) {
+		credentials, err := load()
+		return credentials, "", err
+	}
 }
 
-func loadCredentialsFromEnvironment() (credentials awsCredentials) {
+func loadCredentialsFromEnvironment() (credentials awsCredentials, err error) {
 	credentials.AccessKeyID = os.Getenv(envAccessKeyID)
 	if credentials.AccessKeyID == "" {
 		credentials.AccessKeyID = os.Getenv(envAccessKey)
@@ -72,106 +125,90 @@ func loadCredentialsFromEnvironment() (credentials awsCredentials) {
 	if credentials.SecretAccessKey == "" {
 		credentials.SecretAccessKey = os.Getenv(envSecretKey)
 	}
-	credentials.SecurityToken = os.Getenv(envSecurityToken)
-	return credentials
-}
-
-// onEC2 checks to see if the program is running on an EC2 instance.
-// It does this by looking for the EC2 metadata service.
-// This caches that information in a struct so that it doesn't waste time.
-func onEC2() bool {
-	if location == nil {
-		location = &awsLocation{}
+	credentials.SecurityToken = os.Getenv(envSessionToken)
+	if credentials.SecurityToken == "" {
+		credentials.SecurityToken = os.Getenv(envSecurityToken)
 	}
-	if !(location.checked) {
-		c, err := net.DialTimeout("tcp", "169.254.169.254:80", time.Millisecond*100)
-
+	credentials.Source = SourceEnvironment
+	if !credentials.complete() {
+		return credentials, errEnvironmentCredentialsMissing
+	}
+	if expiration := os.Getenv(envCredentialExpiration); len(expiration) > 0 {
+		credentials.Expiration, err = time.Parse(time.RFC3339, expiration)
 		if err != nil {
-			location.ec2 = false
-		} else {
-			_ = c.Close()
-			location.ec2 = true
+			return awsCredentials{}, fmt.Errorf("invalid %s: %w", envCredentialExpiration, err)
 		}
-		location.checked = true
 	}
-
-	return location.ec2
+	return credentials, nil
 }
 
-type awsLocation struct {
-	ec2     bool
-	checked bool
+// environmentRegion reads the region from the environment variables used by the AWS SDKs and CLI.
+func environmentRegion() string {
+	if region := os.Getenv(envRegion); len(region) > 0 {
+		return region
+	}
+	return os.Getenv(envDefaultRegion)
 }
 
-var location *awsLocation
-
-// getIAMRoleList gets a list of the roles that are available to this instance
-func getIAMRoleList() []string {
-	var roles []string
-	address := "http://169.254.169.254/latest/meta-data/iam/security-credentials/"
-
-	client := &http.Client{}
-
-	request, err := http.NewRequest("GET", address, nil)
-	if err != nil {
-		return roles
+func loadWebIdentityCredentials() (awsCredentials, error) {
+	if !webIdentityConfigured() {
+		return awsCredentials{}, errWebIdentityNotConfigured
 	}
+	value, err := sharedWebIdentityCredentials().Retrieve()
+	return value.awsCredentials(), err
+}
 
-	response, err := client.Do(request)
+func loadSharedProfileCredentials() (awsCredentials, string, error) {
+	profile, err := loadSharedProfile("")
 	if err != nil {
-		return roles
-	}
-	defer closeHandle(response.Body)
-
-	scanner := bufio.NewScanner(response.Body)
-	for scanner.Scan() {
-		roles = append(roles, scanner.Text())
+		return awsCredentials{}, "", err
 	}
-	return roles
+	credentials, err := profile.resolveCredentials()
+	return credentials, profile.region, err
 }
 
-func getIAMRoleCredentials() awsCredentials {
-	roles := getIAMRoleList()
-
-	if len(roles) == 0 {
-		return awsCredentials{}
+func loadContainerCredentials() (awsCredentials, error) {
+	if !containerCredentialsConfigured() {
+		return awsCredentials{}, errContainerCredentialsNotConfigured
 	}
+	value, err := sharedContainerCredentials().Retrieve()
+	return value.awsCredentials(), err
+}
 
-	// Use the first role in the list
-	role := roles[0]
-
-	address := "http://169.254.169.254/latest/meta-data/iam/security-credentials/"
-
-	// Create the full URL of the role
-	var buffer bytes.Buffer
-	buffer.WriteString(address)
-	buffer.WriteString(role)
-	roleURL := buffer.String()
+func loadIAMRoleCredentials() (awsCredentials, error) {
+	value, err := iamRoleCredentials.Retrieve() // cached, as with the IAMRoleCredentials option
+	return value.awsCredentials(), err
+}
 
-	// Get the role
-	roleRequest, err := http.NewRequest("GET", roleURL, nil)
-	if err != nil {
-		return awsCredentials{}
+// onEC2 checks to see if the program is running on an EC2 instance.
+// It does this by looking for the EC2 metadata service.
+// This caches that information (for the address of the service) in a struct so that it doesn't waste time.
+func onEC2() bool {
+	address := instanceMetadataAddress()
+	if current := location.Load(); current != nil && current.address == address {
+		return current.ec2
 	}
 
-	client := &http.Client{Timeout: time.Second * 5}
-	roleResponse, err := client.Do(roleRequest)
-
-	if err != nil {
-		return awsCredentials{}
+	probed := &awsLocation{address: address}
+	if c, err := net.DialTimeout("tcp", address, time.Millisecond*100); err == nil {
+		_ = c.Close()
+		probed.ec2 = true
 	}
-	defer closeHandle(roleResponse.Body)
-
-	roleBuffer := new(bytes.Buffer)
-	_, _ = roleBuffer.ReadFrom(roleResponse.Body)
+	location.Store(probed)
+	return probed.ec2
+}
 
-	credentials := awsCredentials{}
+type awsLocation struct {
+	address string
+	ec2     bool
+}
 
-	err = json.Unmarshal(roleBuffer.Bytes(), &credentials)
-	if err != nil {
-		return awsCredentials{}
-	}
+// location is the outcome of the latest probe, shared by requests signed concurrently.
+var location atomic.Pointer[awsLocation]
 
-	return credentials
+// getIAMRoleCredentials gets the credentials of the first role available to this instance
+func getIAMRoleCredentials() (awsCredentials, error) {
+	return instanceMetadata.iamRoleCredentials()
 }
+
 func closeHandle(closer io.Closer) { _ = closer.Close() }
@@ -0,0 +1,138 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CredentialsProvider supplies (potentially temporary) credentials for signing requests.
+// See CredentialsFrom and NewCachedCredentialsProvider.
+type CredentialsProvider interface {
+	// Retrieve resolves the credentials or returns an error describing why they couldn't be resolved.
+	Retrieve() (CredentialsValue, error)
+
+	// IsExpired indicates whether the most recently retrieved credentials should be retrieved again.
+	IsExpired() bool
+}
+
+// CredentialsValue contains the credentials supplied by a CredentialsProvider.
+// Credentials without an Expiration never expire. Source names the option
+// (or provider) that supplied the credentials (see SourceEnvironment, etc.).
+type CredentialsValue struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+	Source          string
+}
+
+func newCredentialsValue(credentials awsCredentials) CredentialsValue {
+	return CredentialsValue{
+		AccessKeyID:     credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey,
+		SessionToken:    credentials.SecurityToken,
+		Expiration:      credentials.Expiration,
+		Source:          credentials.Source,
+	}
+}
+
+func (this CredentialsValue) awsCredentials() awsCredentials {
+	return awsCredentials{
+		AccessKeyID:     this.AccessKeyID,
+		SecretAccessKey: this.SecretAccessKey,
+		SecurityToken:   this.SessionToken,
+		Expiration:      this.Expiration,
+		Source:          this.Source,
+	}
+}
+
+// NewCachedCredentialsProvider wraps the provider so that credentials are only retrieved
+// again once they are (about to be) expired. It is safe for concurrent use.
+func NewCachedCredentialsProvider(provider CredentialsProvider) CredentialsProvider {
+	return &cachedCredentialsProvider{inner: provider}
+}
+
+type cachedCredentialsProvider struct {
+	mutex       sync.Mutex
+	inner       CredentialsProvider
+	credentials awsCredentials
+	cached      bool
+}
+
+func (this *cachedCredentialsProvider) Retrieve() (CredentialsValue, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if !this.isExpired() {
+		return newCredentialsValue(this.credentials), nil
+	}
+
+	value, err := this.inner.Retrieve()
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	this.credentials = value.awsCredentials()
+	this.cached = true
+	return value, nil
+}
+
+func (this *cachedCredentialsProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.isExpired()
+}
+func (this *cachedCredentialsProvider) isExpired() bool {
+	return !this.cached || this.credentials.expired() || this.inner.IsExpired()
+}
+
+// sharedCredentials supplies a single (cached) provider for each key (which identifies the configuration
+// of the provider) so that credentials aren't retrieved for every request, yet a change of configuration
+// (such as in the environment) takes effect at once.
+func sharedCredentials(key string, create func() CredentialsProvider) CredentialsProvider {
+	sharedCredentialsMutex.Lock()
+	defer sharedCredentialsMutex.Unlock()
+
+	provider, found := sharedCredentialsProviders[key]
+	if !found {
+		provider = create()
+		sharedCredentialsProviders[key] = provider
+	}
+	return provider
+}
+
+var (
+	sharedCredentialsMutex     sync.Mutex
+	sharedCredentialsProviders = make(map[string]CredentialsProvider)
+)
+
+// NewIAMRoleCredentialsProvider creates a provider of the credentials of the EC2 instance's
+// IAM role, which are cached until they are about to expire.
+func NewIAMRoleCredentialsProvider() CredentialsProvider {
+	return NewCachedCredentialsProvider(new(iamRoleCredentialsProvider))
+}
+
+type iamRoleCredentialsProvider struct{}
+
+func (this *iamRoleCredentialsProvider) Retrieve() (CredentialsValue, error) {
+	if !onEC2() { // rather than waiting on the instance metadata service, which won't answer
+		return CredentialsValue{}, fmt.Errorf("%w: %w", errIAMRoleCredentialsUnavailable, errNotOnEC2)
+	}
+	credentials, err := getIAMRoleCredentials()
+	if err != nil {
+		return CredentialsValue{}, fmt.Errorf("%w: %w", errIAMRoleCredentialsUnavailable, err)
+	}
+	if !credentials.complete() {
+		return CredentialsValue{}, errIAMRoleCredentialsUnavailable
+	}
+	credentials.Source = SourceIAMRole
+	return newCredentialsValue(credentials), nil
+}
+
+// IsExpired defers to the expiration of the credentials, which are always temporary.
+func (this *iamRoleCredentialsProvider) IsExpired() bool { return false }
+
+var iamRoleCredentials = NewIAMRoleCredentialsProvider()
+
+var errIAMRoleCredentialsUnavailable = errors.New("credentials unavailable from the EC2 instance metadata service")
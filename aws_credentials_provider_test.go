@@ -0,0 +1,115 @@
+package s3
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestCredentialsProviderFixture(t *testing.T) {
+	gunit.Run(new(CredentialsProviderFixture), t)
+}
+
+type CredentialsProviderFixture struct {
+	*gunit.Fixture
+	inner  *FakeCredentialsProvider
+	cached CredentialsProvider
+}
+
+func (this *CredentialsProviderFixture) Setup() {
+	this.inner = &FakeCredentialsProvider{value: CredentialsValue{
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      time.Now().Add(time.Hour),
+	}}
+	this.cached = NewCachedCredentialsProvider(this.inner)
+}
+
+func (this *CredentialsProviderFixture) TestCachedCredentialsAreOnlyRetrievedOnce() {
+	this.So(this.cached.IsExpired(), should.BeTrue)
+
+	first, err1 := this.cached.Retrieve()
+	second, err2 := this.cached.Retrieve()
+
+	this.So(err1, should.BeNil)
+	this.So(err2, should.BeNil)
+	this.So(first, should.Resemble, this.inner.value)
+	this.So(second, should.Resemble, this.inner.value)
+	this.So(this.inner.retrievals, should.Equal, 1)
+	this.So(this.cached.IsExpired(), should.BeFalse)
+}
+
+func (this *CredentialsProviderFixture) TestExpiringCredentialsAreRefreshed() {
+	this.inner.value.Expiration = time.Now().Add(time.Minute) // within the refresh window
+	_, _ = this.cached.Retrieve()
+	this.So(this.cached.IsExpired(), should.BeTrue)
+
+	this.inner.value.AccessKeyID = "refreshed"
+	this.inner.value.Expiration = time.Now().Add(time.Hour)
+	value, err := this.cached.Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "refreshed")
+	this.So(this.inner.retrievals, should.Equal, 2)
+}
+
+func (this *CredentialsProviderFixture) TestInnerProviderDecidesExpiration() {
+	_, _ = this.cached.Retrieve()
+	this.inner.expired = true
+	_, _ = this.cached.Retrieve()
+	this.So(this.inner.retrievals, should.Equal, 2)
+}
+
+func (this *CredentialsProviderFixture) TestRetrievalFailure() {
+	this.inner.err = errors.New("failure")
+	value, err := this.cached.Retrieve()
+	this.So(err, should.Equal, this.inner.err)
+	this.So(value, should.Resemble, CredentialsValue{})
+	this.So(this.cached.IsExpired(), should.BeTrue)
+}
+
+func (this *CredentialsProviderFixture) TestCredentialsFrom() {
+	option := CredentialsFrom(this.cached)
+
+	request1, err1 := NewRequest(GET, Bucket("bucket"), Key("key"), option)
+	request2, err2 := NewRequest(GET, Bucket("bucket"), Key("key"), option)
+
+	this.So(err1, should.BeNil)
+	this.So(err2, should.BeNil)
+	this.So(request1.Header.Get("Authorization"), should.ContainSubstring, "Credential=access/")
+	this.So(request1.Header.Get("X-Amz-Security-Token"), should.Equal, "token")
+	this.So(request2.Header.Get("Authorization"), should.ContainSubstring, "Credential=access/")
+	this.So(this.inner.retrievals, should.Equal, 1)
+}
+
+func (this *CredentialsProviderFixture) TestCredentialsFromFailure() {
+	this.inner.err = errors.New("failure")
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), CredentialsFrom(this.inner))
+
+	this.So(request, should.BeNil)
+	this.So(errors.Is(err, ErrCredentialsMissing), should.BeTrue)
+	this.So(errors.Is(err, this.inner.err), should.BeTrue)
+}
+
+////////////////////////////////////////////////////////////////
+
+type FakeCredentialsProvider struct {
+	value      CredentialsValue
+	err        error
+	expired    bool
+	retrievals int
+}
+
+func (this *FakeCredentialsProvider) Retrieve() (CredentialsValue, error) {
+	this.retrievals++
+	return this.value, this.err
+}
+
+func (this *FakeCredentialsProvider) IsExpired() bool {
+	return this.expired
+}
@@ -0,0 +1,395 @@
+package s3
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestCredentialsFixture(t *testing.T) {
+	gunit.Run(new(CredentialsFixture), t, gunit.Options.AllSequential())
+}
+
+type CredentialsFixture struct {
+	*gunit.Fixture
+	environment *TemporaryEnvironment
+	folder      string
+	metadata    *FakeInstanceMetadataService
+	server      *httptest.Server
+	original    *instanceMetadataClient
+}
+
+func (this *CredentialsFixture) Setup() {
+	this.folder, _ = ioutil.TempDir("", "s3-credentials")
+	this.environment = NewTemporaryEnvironment(
+		envAccessKeyID, envAccessKey, envSecretAccessKey, envSecretKey, envSecurityToken,
+		envSessionToken, envCredentialExpiration, envRegion, envDefaultRegion,
+		envProfile, envSharedCredentialsFile, envConfigFile,
+		envContainerCredentialsRelativeURI, envContainerCredentialsFullURI,
+		envRoleARN, envWebIdentityTokenFile,
+	)
+	this.environment.Set(envSharedCredentialsFile, filepath.Join(this.folder, "missing"))
+	this.environment.Set(envConfigFile, filepath.Join(this.folder, "missing"))
+
+	this.metadata = NewFakeInstanceMetadataService()
+	this.server = httptest.NewServer(this.metadata)
+	this.original = instanceMetadata
+	instanceMetadata = newInstanceMetadataClient(this.server.URL)
+	iamRoleCredentials = NewIAMRoleCredentialsProvider()
+	location.Store(nil)
+}
+func (this *CredentialsFixture) Teardown() {
+	instanceMetadata = this.original
+	location.Store(nil)
+	iamRoleCredentials = NewIAMRoleCredentialsProvider()
+	this.server.Close()
+	this.environment.Restore()
+	_ = os.RemoveAll(this.folder)
+}
+
+func (this *CredentialsFixture) TestEnvironmentCredentials() {
+	this.environment.Set(envAccessKey, "access")
+	this.environment.Set(envSecretKey, "secret")
+	this.environment.Set(envSecurityToken, "token")
+
+	credentials, err := loadCredentialsFromEnvironment()
+
+	this.So(err, should.BeNil)
+	this.So(credentials, should.Resemble, awsCredentials{
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		SecurityToken:   "token",
+		Source:          SourceEnvironment,
+	})
+}
+
+func (this *CredentialsFixture) TestEnvironmentSessionTokenAndExpiration() {
+	this.environment.Set(envAccessKeyID, "access")
+	this.environment.Set(envSecretAccessKey, "secret")
+	this.environment.Set(envSecurityToken, "legacy-token")
+	this.environment.Set(envSessionToken, "session-token")
+	this.environment.Set(envCredentialExpiration, "2030-01-02T03:04:05Z")
+
+	credentials, err := loadCredentialsFromEnvironment()
+
+	this.So(err, should.BeNil)
+	this.So(credentials.SecurityToken, should.Equal, "session-token")
+	this.So(credentials.Expiration, should.Equal, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+}
+
+func (this *CredentialsFixture) TestInvalidEnvironmentExpiration() {
+	this.environment.Set(envAccessKeyID, "access")
+	this.environment.Set(envSecretAccessKey, "secret")
+	this.environment.Set(envCredentialExpiration, "tomorrow")
+
+	_, err := ResolveCredentials(EnvironmentCredentials())
+
+	this.So(errors.Is(err, ErrCredentialsMissing), should.BeTrue)
+	this.So(err.Error(), should.ContainSubstring, envCredentialExpiration)
+}
+
+func (this *CredentialsFixture) TestExpiredEnvironmentCredentials() {
+	this.environment.Set(envAccessKeyID, "access")
+	this.environment.Set(envSecretAccessKey, "secret")
+	this.environment.Set(envSessionToken, "session-token")
+	this.environment.Set(envCredentialExpiration, time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+
+	_, err := NewRequest(GET, Bucket("bucket"), Key("key"), EnvironmentCredentials())
+
+	this.So(errors.Is(err, ErrCredentialsExpired), should.BeTrue)
+}
+
+func (this *CredentialsFixture) TestAmbientSessionToken() {
+	this.environment.Set(envAccessKeyID, "access")
+	this.environment.Set(envSecretAccessKey, "secret")
+	this.environment.Set(envSessionToken, "session-token")
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("X-Amz-Security-Token"), should.Equal, "session-token")
+}
+
+func (this *CredentialsFixture) TestDefaultRegion() {
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), Credentials("access", "secret"))
+
+	this.So(err, should.BeNil)
+	this.So(request.URL.Host, should.Equal, "s3.amazonaws.com")
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/us-east-1/s3/")
+}
+
+func (this *CredentialsFixture) TestRegionFromEnvironment() {
+	this.environment.Set(envDefaultRegion, "eu-west-1")
+	request, _ := NewRequest(GET, Bucket("bucket"), Key("key"), Credentials("access", "secret"))
+	this.So(request.URL.Host, should.Equal, "s3-eu-west-1.amazonaws.com")
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/eu-west-1/s3/")
+
+	this.environment.Set(envRegion, "ap-south-1")
+	request, _ = NewRequest(GET, Bucket("bucket"), Key("key"), Credentials("access", "secret"))
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/ap-south-1/s3/")
+}
+
+func (this *CredentialsFixture) TestExplicitRegionOverridesEnvironment() {
+	this.environment.Set(envRegion, "ap-south-1")
+	request, _ := NewRequest(GET, Bucket("bucket"), Key("key"), Credentials("access", "secret"), Region("us-west-2"))
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/us-west-2/s3/")
+}
+
+func (this *CredentialsFixture) TestEnvironmentRegionOption() {
+	this.environment.Set(envRegion, "ap-south-1")
+	request, _ := NewRequest(GET, Bucket("bucket"), Key("key"), Credentials("access", "secret"), Region("us-west-2"), EnvironmentRegion())
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/ap-south-1/s3/")
+
+	this.environment.Set(envRegion, "")
+	request, _ = NewRequest(GET, Bucket("bucket"), Key("key"), Credentials("access", "secret"), Region("us-west-2"), EnvironmentRegion())
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/us-west-2/s3/")
+}
+
+func (this *CredentialsFixture) TestMissingEnvironmentCredentials() {
+	this.environment.Set(envAccessKeyID, "access")
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), EnvironmentCredentials())
+
+	this.So(request, should.BeNil)
+	this.So(errors.Is(err, ErrCredentialsMissing), should.BeTrue)
+	this.So(errors.Is(err, errEnvironmentCredentialsMissing), should.BeTrue)
+}
+
+func (this *CredentialsFixture) TestMissingAmbientCredentialsExplainEachSource() {
+	this.metadata.roles = ""
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(request, should.BeNil)
+	this.So(errors.Is(err, ErrCredentialsMissing), should.BeTrue)
+	this.So(errors.Is(err, errEnvironmentCredentialsMissing), should.BeTrue)
+	this.So(errors.Is(err, errWebIdentityNotConfigured), should.BeTrue)
+	this.So(errors.Is(err, ErrProfileNotFound), should.BeTrue)
+	this.So(errors.Is(err, errContainerCredentialsNotConfigured), should.BeTrue)
+	this.So(errors.Is(err, errNoIAMRole), should.BeTrue)
+}
+
+func (this *CredentialsFixture) TestMissingAmbientCredentialsOffEC2() {
+	this.server.Close()
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(request, should.BeNil)
+	this.So(errors.Is(err, ErrCredentialsMissing), should.BeTrue)
+	this.So(errors.Is(err, errNotOnEC2), should.BeTrue)
+}
+
+func (this *CredentialsFixture) TestAmbientIAMRoleCredentialsAreCachedAcrossRequests() {
+	first, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+	this.So(err, should.BeNil)
+	reads, tokenRequests := this.metadata.reads, this.metadata.tokenRequests
+
+	second, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(first.Header.Get("X-Amz-Security-Token"), should.Equal, "role-token")
+	this.So(second.Header.Get("X-Amz-Security-Token"), should.Equal, "role-token")
+	this.So(reads, should.Equal, 2)
+	this.So(this.metadata.reads, should.Equal, reads)
+	this.So(this.metadata.tokenRequests, should.Equal, tokenRequests)
+}
+
+func (this *CredentialsFixture) TestLocationIsProbedSafelyFromConcurrentRequests() {
+	var waiter sync.WaitGroup
+	results := make([]bool, 8)
+	for i := range results {
+		waiter.Add(1)
+		go func(i int) {
+			defer waiter.Done()
+			results[i] = onEC2()
+		}(i)
+	}
+	waiter.Wait()
+
+	this.So(results, should.NotContain, false)
+}
+
+func (this *CredentialsFixture) TestIncompleteCredentials() {
+	_, err := NewPresignedGet(Bucket("bucket"), Key("key"), Credentials("access", ""))
+	this.So(err, should.Equal, ErrCredentialsMissing)
+}
+
+func (this *CredentialsFixture) TestExpiredCredentials() {
+	expiration := time.Now().Add(-time.Minute)
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), STSCredentials("access", "secret", "token", expiration))
+
+	this.So(request, should.BeNil)
+	this.So(errors.Is(err, ErrCredentialsExpired), should.BeTrue)
+	this.So(err.Error(), should.ContainSubstring, SourceSTS)
+}
+
+func (this *CredentialsFixture) TestExpirationIsRelativeToTimestamp() {
+	expiration := time.Date(2013, 5, 24, 1, 0, 0, 0, time.UTC)
+	credentials := STSCredentials("access", "secret", "token", expiration)
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), credentials, Timestamp(expiration.Add(-time.Minute)))
+	this.So(err, should.BeNil)
+	this.So(request, should.NotBeNil)
+
+	request, err = NewRequest(GET, Bucket("bucket"), Key("key"), credentials, Timestamp(expiration))
+	this.So(request, should.BeNil)
+	this.So(errors.Is(err, ErrCredentialsExpired), should.BeTrue)
+}
+
+func (this *CredentialsFixture) TestResolveCredentials() {
+	value, err := ResolveCredentials(Credentials("access", "secret"))
+	this.So(err, should.BeNil)
+	this.So(value, should.Resemble, CredentialsValue{AccessKeyID: "access", SecretAccessKey: "secret", Source: SourceStatic})
+}
+
+func (this *CredentialsFixture) TestResolveAmbientCredentialsFromEnvironment() {
+	this.environment.Set(envAccessKeyID, "access")
+	this.environment.Set(envSecretAccessKey, "secret")
+
+	value, err := ResolveCredentials()
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "access")
+	this.So(value.Source, should.Equal, SourceEnvironment)
+}
+
+func (this *CredentialsFixture) TestResolveAmbientCredentialsFromProfile() {
+	filename := filepath.Join(this.folder, "credentials")
+	_ = ioutil.WriteFile(filename, []byte("[default]\naws_access_key_id = profile-access\naws_secret_access_key = profile-secret\n"), 0600)
+	this.environment.Set(envSharedCredentialsFile, filename)
+
+	value, err := ResolveCredentials()
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "profile-access")
+	this.So(value.Source, should.Equal, SourceProfile)
+}
+
+func (this *CredentialsFixture) TestResolveCredentialsFromCustomProvider() {
+	value, err := ResolveCredentials(CredentialsFrom(&FakeCredentialsProvider{
+		value: CredentialsValue{AccessKeyID: "access", SecretAccessKey: "secret"},
+	}))
+
+	this.So(err, should.BeNil)
+	this.So(value.Source, should.Equal, SourceProvider)
+}
+
+func (this *CredentialsFixture) chain() Option {
+	return CompositeOption(EnvironmentCredentials(), IAMRoleCredentials(), Credentials("static-access", "static-secret"))
+}
+
+func (this *CredentialsFixture) TestChainPrefersFirstOption() {
+	this.environment.Set(envAccessKeyID, "environment-access")
+	this.environment.Set(envSecretAccessKey, "environment-secret")
+
+	value, err := ResolveCredentials(this.chain())
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "environment-access")
+	this.So(value.Source, should.Equal, SourceEnvironment)
+	this.So(this.metadata.reads, should.Equal, 0)
+	this.So(this.metadata.tokenRequests, should.Equal, 0)
+}
+
+func (this *CredentialsFixture) TestChainFallsBackToSecondOption() {
+	value, err := ResolveCredentials(this.chain())
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "role-access")
+	this.So(value.Source, should.Equal, SourceIAMRole)
+}
+
+func (this *CredentialsFixture) TestChainFallsBackToLastOption() {
+	this.metadata.roles = ""
+
+	value, err := ResolveCredentials(this.chain())
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "static-access")
+	this.So(value.Source, should.Equal, SourceStatic)
+}
+
+func (this *CredentialsFixture) TestChainOffEC2FallsBackWithoutInstanceMetadata() {
+	this.server.Close()
+
+	value, err := ResolveCredentials(this.chain())
+
+	this.So(err, should.BeNil)
+	this.So(value.Source, should.Equal, SourceStatic)
+}
+
+func (this *CredentialsFixture) TestIAMRoleCredentialsOffEC2() {
+	this.server.Close()
+
+	_, err := ResolveCredentials(IAMRoleCredentials())
+
+	this.So(errors.Is(err, ErrCredentialsMissing), should.BeTrue)
+	this.So(errors.Is(err, errNotOnEC2), should.BeTrue)
+}
+
+func (this *CredentialsFixture) TestChainInReverseOrder() {
+	this.environment.Set(envAccessKeyID, "environment-access")
+	this.environment.Set(envSecretAccessKey, "environment-secret")
+
+	value, err := ResolveCredentials(Credentials("static-access", "static-secret"), IAMRoleCredentials(), EnvironmentCredentials())
+
+	this.So(err, should.BeNil)
+	this.So(value.Source, should.Equal, SourceStatic)
+}
+
+func (this *CredentialsFixture) TestChainSkipsIncompleteCredentials() {
+	value, err := ResolveCredentials(Credentials("", "secret"), Credentials("access", ""), Credentials("access", "secret"))
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "access")
+	this.So(value.SecretAccessKey, should.Equal, "secret")
+}
+
+func (this *CredentialsFixture) TestChainSkipsExpiredCredentials() {
+	value, err := ResolveCredentials(
+		STSCredentials("expired-access", "expired-secret", "token", time.Now().Add(-time.Hour)),
+		STSCredentials("expiring-access", "expiring-secret", "token", time.Now().Add(time.Minute)),
+		STSCredentials("current-access", "current-secret", "token", time.Now().Add(time.Hour)),
+	)
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "current-access")
+}
+
+func (this *CredentialsFixture) TestChainUsesExpiringCredentialsAsLastResort() {
+	value, err := ResolveCredentials(
+		STSCredentials("expired-access", "expired-secret", "token", time.Now().Add(-time.Hour)),
+		STSCredentials("expiring-access", "expiring-secret", "token", time.Now().Add(time.Minute)),
+	)
+
+	this.So(err, should.BeNil)
+	this.So(value.AccessKeyID, should.Equal, "expiring-access")
+}
+
+func (this *CredentialsFixture) TestChainOfExpiredCredentials() {
+	_, err := ResolveCredentials(
+		STSCredentials("expired-access", "expired-secret", "token", time.Now().Add(-time.Hour)),
+		Credentials("", ""),
+	)
+
+	this.So(errors.Is(err, ErrCredentialsExpired), should.BeTrue)
+}
+
+func (this *CredentialsFixture) TestChainSignsWithChosenCredentials() {
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"),
+		STSCredentials("expired-access", "expired-secret", "expired-token", time.Now().Add(-time.Hour)),
+		Credentials("static-access", "static-secret"))
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=static-access/")
+	this.So(request.Header.Get("X-Amz-Security-Token"), should.BeBlank)
+}
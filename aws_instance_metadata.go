@@ -0,0 +1,224 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// instanceMetadataClient reads from the EC2 instance metadata service (IMDS), preferring
+// session-oriented (IMDSv2) requests, and falling back to IMDSv1 requests when a session
+// token can't be obtained. Without an endpoint, the client resolves it (from the environment)
+// for each request.
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/configuring-instance-metadata-service.html
+type instanceMetadataClient struct {
+	endpoint string
+	client   *http.Client
+
+	mutex              sync.Mutex
+	tokenEndpoint      string
+	token              string
+	tokenExpiration    time.Time
+	fallbackExpiration time.Time
+}
+
+func newInstanceMetadataClient(endpoint string) *instanceMetadataClient {
+	return &instanceMetadataClient{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: time.Second * 5},
+	}
+}
+
+// iamRoleCredentials gets the credentials of the first role available to this instance.
+func (this *instanceMetadataClient) iamRoleCredentials() (credentials awsCredentials, err error) {
+	roles, err := this.iamRoleList()
+	if err != nil {
+		return credentials, err
+	}
+	if len(roles) == 0 {
+		return credentials, errNoIAMRole
+	}
+
+	body, err := this.get(instanceMetadataCredentialsPath + roles[0])
+	if err != nil {
+		return credentials, err
+	}
+
+	var document struct {
+		Code string
+		awsCredentials
+	}
+	if err = json.Unmarshal(body, &document); err != nil {
+		return credentials, fmt.Errorf("malformed credentials of IAM role %q: %w", roles[0], err)
+	}
+	if len(document.Code) > 0 && document.Code != "Success" {
+		return credentials, fmt.Errorf("credentials of IAM role %q unavailable: %s", roles[0], document.Code)
+	}
+	return document.awsCredentials, nil
+}
+
+// iamRoleList gets a list of the roles that are available to this instance.
+func (this *instanceMetadataClient) iamRoleList() (roles []string, err error) {
+	body, err := this.get(instanceMetadataCredentialsPath)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if role := strings.TrimSpace(scanner.Text()); len(role) > 0 {
+			roles = append(roles, role)
+		}
+	}
+	return roles, scanner.Err()
+}
+
+func (this *instanceMetadataClient) get(path string) ([]byte, error) {
+	body, status, err := this.tryGet(path, this.sessionToken())
+	if status == http.StatusUnauthorized {
+		this.discardSessionToken() // the token expired (or was revoked), so try again with a new one
+		body, status, err = this.tryGet(path, this.sessionToken())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("instance metadata service responded to %s with %d %s", path, status, http.StatusText(status))
+	}
+	return body, nil
+}
+
+func (this *instanceMetadataClient) tryGet(path, token string) (body []byte, status int, err error) {
+	request, err := http.NewRequest(http.MethodGet, this.baseURL()+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(token) > 0 {
+		request.Header.Set(instanceMetadataTokenHeader, token)
+	}
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer closeHandle(response.Body)
+
+	body, err = ioutil.ReadAll(response.Body)
+	return body, response.StatusCode, err
+}
+
+// sessionToken returns a cached (or new) IMDSv2 session token, or nothing at all if the service doesn't
+// provide one (meaning only IMDSv1 is available). Having failed to obtain a token, the client falls back
+// to IMDSv1 for a while rather than waiting on (and likely failing) another request for each read.
+func (this *instanceMetadataClient) sessionToken() string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	endpoint := this.baseURL()
+	if endpoint != this.tokenEndpoint {
+		this.resetSessionToken(endpoint)
+	}
+	if len(this.token) > 0 && time.Now().Before(this.tokenExpiration) {
+		return this.token
+	}
+	if time.Now().Before(this.fallbackExpiration) {
+		return ""
+	}
+
+	this.resetSessionToken(endpoint)
+	this.token = this.requestSessionToken(endpoint)
+	if len(this.token) == 0 {
+		this.fallbackExpiration = time.Now().Add(instanceMetadataFallbackTTL)
+	} else {
+		this.tokenExpiration = time.Now().Add(instanceMetadataTokenTTL - time.Minute)
+	}
+	return this.token
+}
+
+func (this *instanceMetadataClient) requestSessionToken(endpoint string) string {
+	request, err := http.NewRequest(http.MethodPut, endpoint+instanceMetadataTokenPath, nil)
+	if err != nil {
+		return ""
+	}
+	request.Header.Set(instanceMetadataTokenTTLHeader, formatInt64(int64(instanceMetadataTokenTTL.Seconds())))
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return ""
+	}
+	defer closeHandle(response.Body)
+
+	token, err := ioutil.ReadAll(response.Body)
+	if err != nil || response.StatusCode != http.StatusOK {
+		return ""
+	}
+	return strings.TrimSpace(string(token))
+}
+
+// discardSessionToken forgets the token (or the decision to fall back to IMDSv1) after the service rejected a read.
+func (this *instanceMetadataClient) discardSessionToken() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.resetSessionToken(this.tokenEndpoint)
+}
+
+func (this *instanceMetadataClient) resetSessionToken(endpoint string) {
+	this.tokenEndpoint = endpoint
+	this.token, this.tokenExpiration, this.fallbackExpiration = "", time.Time{}, time.Time{}
+}
+
+// baseURL is the endpoint of the client or else the endpoint configured by the environment.
+func (this *instanceMetadataClient) baseURL() string {
+	if len(this.endpoint) > 0 {
+		return this.endpoint
+	}
+	return strings.TrimSuffix(instanceMetadataEndpoint(), "/")
+}
+
+// instanceMetadataEndpoint allows the endpoint to be overridden as with the AWS SDKs and CLI.
+func instanceMetadataEndpoint() string {
+	if endpoint := os.Getenv(envMetadataServiceEndpoint); len(endpoint) > 0 {
+		return endpoint
+	}
+	return defaultInstanceMetadataEndpoint
+}
+
+// instanceMetadataAddress is the host:port of the endpoint (for checking whether it's reachable).
+func instanceMetadataAddress() string {
+	endpoint, err := url.Parse(instanceMetadata.baseURL())
+	if err != nil || len(endpoint.Host) == 0 {
+		return "169.254.169.254:80"
+	}
+	if len(endpoint.Port()) > 0 {
+		return endpoint.Host
+	}
+	if endpoint.Scheme == "https" {
+		return net.JoinHostPort(endpoint.Hostname(), "443")
+	}
+	return net.JoinHostPort(endpoint.Hostname(), "80")
+}
+
+var instanceMetadata = newInstanceMetadataClient("") // see instanceMetadataEndpoint
+
+var errNoIAMRole = errors.New("no IAM role is associated with this instance")
+
+const (
+	envMetadataServiceEndpoint = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+
+	defaultInstanceMetadataEndpoint = "http://169.254.169.254"
+	instanceMetadataCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+	instanceMetadataTokenPath       = "/latest/api/token"
+	instanceMetadataTokenHeader     = "X-Aws-Ec2-Metadata-Token"
+	instanceMetadataTokenTTLHeader  = "X-Aws-Ec2-Metadata-Token-Ttl-Seconds"
+	instanceMetadataTokenTTL        = time.Hour * 6
+	instanceMetadataFallbackTTL     = time.Minute * 5
+)
@@ -0,0 +1,236 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestInstanceMetadataFixture(t *testing.T) {
+	gunit.Run(new(InstanceMetadataFixture), t, gunit.Options.AllSequential())
+}
+
+type InstanceMetadataFixture struct {
+	*gunit.Fixture
+	service *FakeInstanceMetadataService
+	server  *httptest.Server
+	client  *instanceMetadataClient
+}
+
+func (this *InstanceMetadataFixture) Setup() {
+	this.service = NewFakeInstanceMetadataService()
+	this.server = httptest.NewServer(this.service)
+	this.client = newInstanceMetadataClient(this.server.URL + "/")
+}
+func (this *InstanceMetadataFixture) Teardown() {
+	this.server.Close()
+}
+
+func (this *InstanceMetadataFixture) TestCredentialsWithSessionToken() {
+	this.service.requireToken = true
+
+	credentials, err := this.client.iamRoleCredentials()
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "role-access")
+	this.So(credentials.SecretAccessKey, should.Equal, "role-secret")
+	this.So(credentials.SecurityToken, should.Equal, "role-token")
+	this.So(credentials.Expiration.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)), should.BeTrue)
+	this.So(this.service.tokenTTL, should.Equal, "21600")
+}
+
+func (this *InstanceMetadataFixture) TestSessionTokenIsCached() {
+	this.service.requireToken = true
+
+	_, err1 := this.client.iamRoleCredentials()
+	_, err2 := this.client.iamRoleCredentials()
+
+	this.So(err1, should.BeNil)
+	this.So(err2, should.BeNil)
+	this.So(this.service.tokensIssued, should.Equal, 1)
+	this.So(this.service.reads, should.Equal, 4)
+}
+
+func (this *InstanceMetadataFixture) TestRejectedSessionTokenIsReplaced() {
+	this.service.requireToken = true
+	_, _ = this.client.iamRoleList()
+	this.service.revokeTokens()
+
+	roles, err := this.client.iamRoleList()
+
+	this.So(err, should.BeNil)
+	this.So(roles, should.Resemble, []string{"role"})
+	this.So(this.service.tokensIssued, should.Equal, 2)
+}
+
+func (this *InstanceMetadataFixture) TestFallBackToVersion1() {
+	this.service.tokensUnsupported = true
+
+	credentials, err := this.client.iamRoleCredentials()
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "role-access")
+	this.So(this.service.tokensIssued, should.Equal, 0)
+}
+
+func (this *InstanceMetadataFixture) TestFallBackToVersion1IsCached() {
+	this.service.tokensUnsupported = true
+
+	_, err1 := this.client.iamRoleCredentials()
+	_, err2 := this.client.iamRoleCredentials()
+
+	this.So(err1, should.BeNil)
+	this.So(err2, should.BeNil)
+	this.So(this.service.tokenRequests, should.Equal, 1)
+	this.So(this.service.reads, should.Equal, 4)
+}
+
+func (this *InstanceMetadataFixture) TestFallBackToVersion1Expires() {
+	this.service.tokensUnsupported = true
+	_, _ = this.client.iamRoleList()
+	this.client.fallbackExpiration = time.Now().Add(-time.Second)
+	this.service.tokensUnsupported = false
+	this.service.requireToken = true
+
+	roles, err := this.client.iamRoleList()
+
+	this.So(err, should.BeNil)
+	this.So(roles, should.Resemble, []string{"role"})
+	this.So(this.service.tokensIssued, should.Equal, 1)
+}
+
+func (this *InstanceMetadataFixture) TestFallBackToVersion1IsAbandonedWhenRejected() {
+	this.service.tokensUnsupported = true
+	_, _ = this.client.iamRoleList()
+	this.service.tokensUnsupported = false
+	this.service.requireToken = true
+
+	roles, err := this.client.iamRoleList()
+
+	this.So(err, should.BeNil)
+	this.So(roles, should.Resemble, []string{"role"})
+	this.So(this.service.tokensIssued, should.Equal, 1)
+}
+
+func (this *InstanceMetadataFixture) TestEndpointFromEnvironmentIsResolvedForEachRequest() {
+	environment := NewTemporaryEnvironment(envMetadataServiceEndpoint)
+	defer environment.Restore()
+	client := newInstanceMetadataClient("")
+
+	environment.Set(envMetadataServiceEndpoint, "http://169.254.169.254/")
+	this.So(client.baseURL(), should.Equal, "http://169.254.169.254")
+
+	environment.Set(envMetadataServiceEndpoint, this.server.URL)
+	roles, err := client.iamRoleList()
+
+	this.So(err, should.BeNil)
+	this.So(roles, should.Resemble, []string{"role"})
+}
+
+func (this *InstanceMetadataFixture) TestNoRole() {
+	this.service.roles = ""
+
+	credentials, err := this.client.iamRoleCredentials()
+
+	this.So(err, should.Equal, errNoIAMRole)
+	this.So(credentials, should.Resemble, awsCredentials{})
+}
+
+func (this *InstanceMetadataFixture) TestUnsuccessfulCredentials() {
+	this.service.code = "Failure"
+
+	_, err := this.client.iamRoleCredentials()
+
+	this.So(err, should.NotBeNil)
+	this.So(err.Error(), should.ContainSubstring, "Failure")
+}
+
+func (this *InstanceMetadataFixture) TestServiceFailure() {
+	this.server.Close()
+
+	_, err := this.client.iamRoleCredentials()
+
+	this.So(err, should.NotBeNil)
+}
+
+////////////////////////////////////////////////////////////////
+
+type FakeInstanceMetadataService struct {
+	mutex             sync.Mutex
+	requireToken      bool
+	tokensUnsupported bool
+	roles             string
+	code              string
+	tokens            map[string]bool
+	tokenTTL          string
+	tokensIssued      int
+	tokenRequests     int
+	reads             int
+}
+
+func NewFakeInstanceMetadataService() *FakeInstanceMetadataService {
+	return &FakeInstanceMetadataService{
+		roles:  "role\n",
+		code:   "Success",
+		tokens: make(map[string]bool),
+	}
+}
+
+func (this *FakeInstanceMetadataService) revokeTokens() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.tokens = make(map[string]bool)
+}
+
+func (this *FakeInstanceMetadataService) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if request.Method == http.MethodPut && request.URL.Path == instanceMetadataTokenPath {
+		this.tokenRequests++
+		if this.tokensUnsupported {
+			response.WriteHeader(http.StatusForbidden)
+			return
+		}
+		this.tokensIssued++
+		this.tokenTTL = request.Header.Get(instanceMetadataTokenTTLHeader)
+		token := fmt.Sprintf("token-%d", this.tokensIssued)
+		this.tokens[token] = true
+		_, _ = io.WriteString(response, token)
+		return
+	}
+
+	if request.Method != http.MethodGet {
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if this.requireToken && !this.tokens[request.Header.Get(instanceMetadataTokenHeader)] {
+		response.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	this.reads++
+	switch role := strings.TrimPrefix(request.URL.Path, instanceMetadataCredentialsPath); role {
+	case "":
+		_, _ = io.WriteString(response, this.roles)
+	case "role":
+		_, _ = fmt.Fprintf(response, `{
+  "Code" : "%s",
+  "Type" : "AWS-HMAC",
+  "AccessKeyId" : "role-access",
+  "SecretAccessKey" : "role-secret",
+  "Token" : "role-token",
+  "Expiration" : "2030-01-02T03:04:05Z"
+}`, this.code)
+	default:
+		response.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,203 @@
+package s3
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// sharedProfile contains the settings of a named profile found in the shared credentials
+// file (~/.aws/credentials) and/or the shared config file (~/.aws/config).
+// https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-files.html
+type sharedProfile struct {
+	name              string
+	credentials       awsCredentials
+	credentialProcess string
+	region            string
+}
+
+// loadSharedProfile loads the named profile (or, when blank, the profile named by $AWS_PROFILE
+// or else the "default" profile). Settings in the credentials file take precedence.
+func loadSharedProfile(name string) (profile sharedProfile, err error) {
+	profile.name = sharedProfileName(name)
+
+	config, err := loadINIFile(sharedConfigFilename())
+	if err != nil {
+		return profile, err
+	}
+	credentials, err := loadINIFile(sharedCredentialsFilename())
+	if err != nil {
+		return profile, err
+	}
+
+	configSection, inConfig := config[sharedConfigSectionName(profile.name)]
+	credentialsSection, inCredentials := credentials[profile.name]
+	if !inConfig && !inCredentials {
+		return profile, fmt.Errorf("%w: %q", ErrProfileNotFound, profile.name)
+	}
+
+	settings := make(map[string]string)
+	for key, value := range configSection {
+		settings[key] = value
+	}
+	for key, value := range credentialsSection {
+		settings[key] = value
+	}
+
+	profile.credentials.AccessKeyID = settings["aws_access_key_id"]
+	profile.credentials.SecretAccessKey = settings["aws_secret_access_key"]
+	profile.credentials.SecurityToken = settings["aws_session_token"]
+	profile.credentialProcess = settings["credential_process"]
+	profile.region = settings["region"]
+	return profile, nil
+}
+
+// resolveCredentials prefers the static credentials of the profile, and otherwise runs its credential_process.
+func (this sharedProfile) resolveCredentials() (awsCredentials, error) {
+	if this.credentials.complete() {
+		credentials := this.credentials
+		credentials.Source = SourceProfile
+		return credentials, nil
+	}
+	if len(this.credentialProcess) > 0 {
+		value, err := sharedProcessCredentials(this.credentialProcess).Retrieve()
+		credentials := value.awsCredentials()
+		credentials.Source = SourceProfile
+		return credentials, err
+	}
+	return awsCredentials{}, fmt.Errorf("profile %q has no aws_access_key_id and aws_secret_access_key (or credential_process)", this.name)
+}
+
+func sharedProfileName(name string) string {
+	if len(name) == 0 {
+		name = os.Getenv(envProfile)
+	}
+	if len(name) == 0 {
+		name = defaultProfileName
+	}
+	return name
+}
+
+// sharedConfigSectionName accounts for the "profile " prefix of all but the
+// default profile's section in the config file (but not the credentials file).
+func sharedConfigSectionName(profile string) string {
+	if profile == defaultProfileName {
+		return profile
+	}
+	return "profile " + profile
+}
+
+func sharedCredentialsFilename() string {
+	return sharedFilename(envSharedCredentialsFile, "credentials")
+}
+func sharedConfigFilename() string {
+	return sharedFilename(envConfigFile, "config")
+}
+func sharedFilename(variable, name string) string {
+	if filename := os.Getenv(variable); len(filename) > 0 {
+		return filename
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// loadINIFile parses the file (treating a missing file as empty) into sections of settings.
+func loadINIFile(filename string) (map[string]map[string]string, error) {
+	if len(filename) == 0 {
+		return nil, nil
+	}
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer closeHandle(file)
+	return parseINI(file)
+}
+
+// parseINI parses the (simplified) INI format of the shared credentials and config files.
+// Indented lines (like the nested settings of the "s3" key) are ignored.
+func parseINI(reader io.Reader) (map[string]map[string]string, error) {
+	sections := make(map[string]map[string]string)
+	var section map[string]string
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			section = sections[name]
+			if section == nil {
+				section = make(map[string]string)
+				sections[name] = section
+			}
+			continue
+		}
+		if section == nil || raw != strings.TrimLeft(raw, " \t") {
+			continue
+		}
+		if equals := strings.Index(line, "="); equals > 0 {
+			key := strings.ToLower(strings.TrimSpace(line[:equals]))
+			section[key] = strings.TrimSpace(line[equals+1:])
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// profileCredentialsProvider retrieves credentials from the shared credentials and config files.
+type profileCredentialsProvider struct {
+	name   string
+	mutex  sync.Mutex
+	region string
+}
+
+func newProfileCredentialsProvider(name string) *profileCredentialsProvider {
+	return &profileCredentialsProvider{name: name}
+}
+
+func (this *profileCredentialsProvider) Retrieve() (CredentialsValue, error) {
+	profile, err := loadSharedProfile(this.name)
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+
+	this.mutex.Lock()
+	this.region = profile.region
+	this.mutex.Unlock()
+
+	credentials, err := profile.resolveCredentials()
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	return newCredentialsValue(credentials), nil
+}
+
+// IsExpired defers to the expiration of the credentials (if any, as from a credential_process).
+func (this *profileCredentialsProvider) IsExpired() bool { return false }
+
+// Region is the region configured for the profile as of the most recent retrieval.
+func (this *profileCredentialsProvider) Region() string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.region
+}
+
+const (
+	envProfile               = "AWS_PROFILE"
+	envSharedCredentialsFile = "AWS_SHARED_CREDENTIALS_FILE"
+	envConfigFile            = "AWS_CONFIG_FILE"
+
+	defaultProfileName = "default"
+)
@@ -0,0 +1,225 @@
+package s3
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestSharedConfigFixture(t *testing.T) {
+	gunit.Run(new(SharedConfigFixture), t, gunit.Options.AllSequential())
+}
+
+type SharedConfigFixture struct {
+	*gunit.Fixture
+	environment *TemporaryEnvironment
+	folder      string
+}
+
+func (this *SharedConfigFixture) Setup() {
+	this.folder, _ = ioutil.TempDir("", "s3-shared-config")
+	this.environment = NewTemporaryEnvironment(
+		envAccessKeyID, envAccessKey, envSecretAccessKey, envSecretKey, envSecurityToken,
+		envProfile, envSharedCredentialsFile, envConfigFile,
+		envContainerCredentialsRelativeURI, envContainerCredentialsFullURI,
+		envRoleARN, envWebIdentityTokenFile, envRegion, envDefaultRegion,
+	)
+	this.environment.Set(envSharedCredentialsFile, this.writeFile("credentials", `
+[default]
+aws_access_key_id = default-access
+aws_secret_access_key = default-secret
+
+[other]
+aws_access_key_id=other-access
+aws_secret_access_key=other-secret
+aws_session_token=other-token
+`))
+	this.environment.Set(envConfigFile, this.writeFile("config", `
+[default]
+region = us-west-2
+
+[profile other]
+region = eu-west-1
+s3 =
+  region = ignored
+
+[profile config-only]
+aws_access_key_id = config-access
+aws_secret_access_key = config-secret
+`))
+}
+func (this *SharedConfigFixture) Teardown() {
+	this.environment.Restore()
+	_ = os.RemoveAll(this.folder)
+}
+
+func (this *SharedConfigFixture) writeFile(name, content string) string {
+	filename := filepath.Join(this.folder, name)
+	_ = ioutil.WriteFile(filename, []byte(content), 0600)
+	return filename
+}
+
+func (this *SharedConfigFixture) TestParseINI() {
+	sections, err := parseINI(strings.NewReader(`
+# comment
+; comment
+[ profile   spaced ]
+Key = value = with equals
+  nested = ignored
+`))
+	this.So(err, should.BeNil)
+	this.So(sections, should.Resemble, map[string]map[string]string{
+		"profile spaced": {"key": "value = with equals"},
+	})
+}
+
+func (this *SharedConfigFixture) TestDefaultProfile() {
+	profile, err := loadSharedProfile("")
+	this.So(err, should.BeNil)
+	this.So(profile.credentials, should.Resemble, awsCredentials{AccessKeyID: "default-access", SecretAccessKey: "default-secret"})
+	this.So(profile.region, should.Equal, "us-west-2")
+}
+
+func (this *SharedConfigFixture) TestProfileFromEnvironment() {
+	this.environment.Set(envProfile, "other")
+	profile, err := loadSharedProfile("")
+	this.So(err, should.BeNil)
+	this.So(profile.name, should.Equal, "other")
+	this.So(profile.credentials.SecurityToken, should.Equal, "other-token")
+	this.So(profile.region, should.Equal, "eu-west-1")
+}
+
+func (this *SharedConfigFixture) TestCredentialsInConfigFile() {
+	profile, err := loadSharedProfile("config-only")
+	this.So(err, should.BeNil)
+	this.So(profile.credentials.AccessKeyID, should.Equal, "config-access")
+	this.So(profile.region, should.BeBlank)
+}
+
+func (this *SharedConfigFixture) TestMissingProfile() {
+	_, err := loadSharedProfile("missing")
+	this.So(errors.Is(err, ErrProfileNotFound), should.BeTrue)
+}
+
+func (this *SharedConfigFixture) TestMissingFiles() {
+	this.environment.Set(envSharedCredentialsFile, filepath.Join(this.folder, "missing"))
+	this.environment.Set(envConfigFile, filepath.Join(this.folder, "missing"))
+	_, err := loadSharedProfile("")
+	this.So(errors.Is(err, ErrProfileNotFound), should.BeTrue)
+}
+
+func (this *SharedConfigFixture) TestProfileCredentialsOption() {
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), ProfileCredentials("other"))
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=other-access/")
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/eu-west-1/s3/")
+	this.So(request.Header.Get("X-Amz-Security-Token"), should.Equal, "other-token")
+}
+
+func (this *SharedConfigFixture) TestProfileRegionDoesNotOverrideExplicitRegion() {
+	request, err := NewRequest(GET, Region("ap-south-1"), Bucket("bucket"), Key("key"), ProfileCredentials("other"))
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/ap-south-1/s3/")
+}
+
+func (this *SharedConfigFixture) TestEnvironmentRegionOverridesProfileRegion() {
+	this.environment.Set(envDefaultRegion, "ap-south-1")
+	request, _ := NewRequest(GET, Bucket("bucket"), Key("key"), ProfileCredentials("other"))
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/ap-south-1/s3/")
+
+	this.environment.Set(envRegion, "ap-northeast-1")
+	request, _ = NewRequest(GET, Bucket("bucket"), Key("key"), ProfileCredentials("other"))
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/ap-northeast-1/s3/")
+}
+
+func (this *SharedConfigFixture) TestAmbientProfileRegion() {
+	request, _ := NewRequest(GET, Bucket("bucket"), Key("key"))
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=default-access/")
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/us-west-2/s3/")
+
+	this.environment.Set(envProfile, "other")
+	request, _ = NewRequest(GET, Bucket("bucket"), Key("key"))
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/eu-west-1/s3/")
+
+	this.environment.Set(envDefaultRegion, "ap-south-1")
+	request, _ = NewRequest(GET, Bucket("bucket"), Key("key"))
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/ap-south-1/s3/")
+}
+
+func (this *SharedConfigFixture) TestAmbientProfileRegionOnlyWithProfileCredentials() {
+	this.environment.Set(envAccessKeyID, "environment-access")
+	this.environment.Set(envSecretAccessKey, "environment-secret")
+
+	request, _ := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/us-east-1/s3/")
+}
+
+func (this *SharedConfigFixture) TestProfileCredentialsOptionFailure() {
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), ProfileCredentials("missing"))
+	this.So(request, should.BeNil)
+	this.So(errors.Is(err, ErrProfileNotFound), should.BeTrue)
+}
+
+func (this *SharedConfigFixture) TestAmbientCredentialsFallBackToProfile() {
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=default-access/")
+}
+
+func (this *SharedConfigFixture) TestAmbientCredentialsPreferEnvironment() {
+	this.environment.Set(envAccessKeyID, "environment-access")
+	this.environment.Set(envSecretAccessKey, "environment-secret")
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=environment-access/")
+}
+
+////////////////////////////////////////////////////////////////
+
+// TemporaryEnvironment clears the named environment variables and later restores their original values.
+// Fixtures that use it must run sequentially (gunit.Options.AllSequential()).
+type TemporaryEnvironment struct {
+	original map[string]*string
+}
+
+func NewTemporaryEnvironment(names ...string) *TemporaryEnvironment {
+	this := &TemporaryEnvironment{original: make(map[string]*string)}
+	for _, name := range names {
+		if value, found := os.LookupEnv(name); found {
+			this.original[name] = &value
+		} else {
+			this.original[name] = nil
+		}
+		_ = os.Unsetenv(name)
+	}
+	return this
+}
+
+func (this *TemporaryEnvironment) Set(name, value string) {
+	if _, found := this.original[name]; !found {
+		value, found := os.LookupEnv(name)
+		if found {
+			this.original[name] = &value
+		} else {
+			this.original[name] = nil
+		}
+	}
+	_ = os.Setenv(name, value)
+}
+
+func (this *TemporaryEnvironment) Restore() {
+	for name, value := range this.original {
+		if value == nil {
+			_ = os.Unsetenv(name)
+		} else {
+			_ = os.Setenv(name, *value)
+		}
+	}
+}
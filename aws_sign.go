@@ -0,0 +1,217 @@
+package s3
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sign signs the request to the service (such as "s3", "sqs", "sts", or "execute-api") in the region in place
+// by way of AWS Signature Version 4, with the credentials specified by the options (or, without any, ambient
+// credentials). The signature is added as the Authorization header or, given the QuerySignature option (and
+// ExpiresIn or ExpireTime), to the query string. The Host, Content-Type, Content-MD5, and X-Amz-* headers
+// are signed unless the SignedHeaders option says otherwise. The payload is read (and replaced) to calculate
+// its checksum unless it is already stated by the X-Amz-Content-Sha256 header or the UnsignedPayload option.
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_aws-signing.html
+func Sign(request *http.Request, service, region string, options ...Option) error {
+	in := new(inputModel).applyOptions(options)
+
+	if err := in.validateCredentials(); err != nil {
+		return err
+	}
+	if in.querySignature && in.expireTime.IsZero() && in.expiresIn <= 0 {
+		return ErrExpirationMissing
+	}
+
+	return newRequestSigner(in, service, region, request).sign()
+}
+
+type requestSigner struct {
+	input       *inputModel
+	service     string
+	region      string
+	request     *http.Request
+	credentials awsCredentials
+	timestamp   string
+	signHeaders map[string]bool // canonical header keys; nil means the headerEligibleForSigning default
+}
+
+func newRequestSigner(input *inputModel, service, region string, request *http.Request) *requestSigner {
+	this := &requestSigner{
+		input:       input,
+		service:     service,
+		region:      region,
+		request:     request,
+		credentials: input.credential(),
+		timestamp:   input.now.UTC().Format(timeFormatV4),
+	}
+	if input.signedHeaders != nil {
+		this.signHeaders = map[string]bool{"Host": true}
+		for _, name := range input.signedHeaders {
+			this.signHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return this
+}
+
+func (this *requestSigner) sign() error {
+	if this.request.Header == nil {
+		this.request.Header = make(http.Header)
+	}
+	bodyDigest, err := this.bodyDigest()
+	if err != nil {
+		return err
+	}
+
+	query := this.request.URL.Query()
+	if this.input.querySignature {
+		this.addQueryParameters(query)
+	} else {
+		this.addHeaders(bodyDigest)
+	}
+
+	canonicalHeaders, signedHeaders := canonicalizeSelectedHeaders(this.headersToSign(), this.eligible, true)
+	if this.input.querySignature {
+		query.Set(headerSignedHeaders, signedHeaders)
+	}
+	signer := &v4Signer{
+		keys: this.credentials,
+		data: &v4RequestData{
+			service:          this.service,
+			region:           this.region,
+			method:           this.request.Method,
+			urlPath:          canonicalURI(this.service, this.request.URL),
+			urlQuery:         canonicalQuery(query),
+			bodyDigest:       bodyDigest,
+			timestamp:        this.timestamp,
+			date:             timestampDateV4(this.timestamp),
+			canonicalHeaders: canonicalHeaders,
+			signedHeaders:    signedHeaders,
+		},
+	}
+	signature := signer.calculateSignature()
+	this.input.trace(signer.signingDetails(signature))
+
+	if this.input.querySignature {
+		this.request.URL.RawQuery = signer.data.urlQuery + "&" + headerSignature + "=" + signature.task3_IntermediateSignature
+	} else {
+		this.request.Header.Set("Authorization", signature.task4_AuthorizationHeader)
+	}
+	return nil
+}
+
+// bodyDigest is the checksum of the payload (or UNSIGNED-PAYLOAD) to include in the canonical request.
+// S3 doesn't require the payload of presigned (query-signed) requests to be signed.
+func (this *requestSigner) bodyDigest() (string, error) {
+	if this.input.unsignedPayload || (this.input.querySignature && this.service == awsS3Service) {
+		return awsV4UnsignedPayload, nil
+	}
+	if digest := this.request.Header.Get("X-Amz-Content-Sha256"); len(digest) > 0 && !this.input.querySignature {
+		return digest, nil
+	}
+	if this.request.Body == nil || this.request.Body == http.NoBody {
+		return hashSHA256(nil), nil
+	}
+	payload, err := ioutil.ReadAll(this.request.Body)
+	_ = this.request.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	this.request.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	this.request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(payload)), nil
+	}
+	return hashSHA256(payload), nil
+}
+
+func (this *requestSigner) addHeaders(bodyDigest string) {
+	this.request.Header.Del("Authorization")
+	this.request.Header.Set(headerDate, this.timestamp)
+	this.signHeader(headerDate)
+	if token := this.credentials.SecurityToken; len(token) > 0 {
+		this.request.Header.Set(headerSecurityToken, token)
+		this.signHeader(headerSecurityToken)
+	}
+	if this.service == awsS3Service || bodyDigest == awsV4UnsignedPayload {
+		this.request.Header.Set("X-Amz-Content-Sha256", bodyDigest)
+		this.signHeader("X-Amz-Content-Sha256")
+	}
+}
+
+func (this *requestSigner) addQueryParameters(query url.Values) {
+	query.Del(headerSignature)
+	query.Set(headerAlgorithm, awsV4SignatureAlgorithm)
+	query.Set(headerCredential, join("/", this.credentials.AccessKeyID, timestampDateV4(this.timestamp),
+		this.region, this.service, awsV4CredentialScopeTerminationString))
+	query.Set(headerDate, this.timestamp)
+	query.Set(headerExpires, this.expiresInSeconds())
+	if token := this.credentials.SecurityToken; len(token) > 0 {
+		query.Set(headerSecurityToken, token)
+	}
+}
+
+func (this *requestSigner) expiresInSeconds() string {
+	if this.input.expiresIn > 0 {
+		return this.input.expiresInSeconds()
+	}
+	return strconv.Itoa(int(this.input.expireTime.Sub(this.input.now).Seconds()))
+}
+
+// signHeader includes a header added by the signer among those selected by the SignedHeaders option.
+func (this *requestSigner) signHeader(key string) {
+	if this.signHeaders != nil {
+		this.signHeaders[key] = true
+	}
+}
+
+func (this *requestSigner) eligible(key string) bool {
+	if this.signHeaders == nil {
+		return headerEligibleForSigning(key)
+	}
+	return this.signHeaders[key]
+}
+
+// headersToSign are the request headers along with the Host header, which net/http keeps elsewhere.
+func (this *requestSigner) headersToSign() http.Header {
+	headers := this.request.Header.Clone()
+	host := this.request.Host
+	if len(host) == 0 {
+		host = this.request.URL.Host
+	}
+	headers.Set("Host", host)
+	return headers
+}
+
+// canonicalURI encodes each segment of the (normalized) path a second time, as required
+// by every service but S3, which neither normalizes nor double-encodes the path.
+func canonicalURI(service string, address *url.URL) string {
+	if service == awsS3Service {
+		if len(address.Path) == 0 {
+			return "/"
+		}
+		return normalizeURI(address.Path)
+	}
+	escaped := address.EscapedPath()
+	cleaned := path.Clean("/" + escaped)
+	if strings.HasSuffix(escaped, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return normalizeURI(cleaned)
+}
+
+// canonicalQuery sorts the parameters by name and then by value.
+func canonicalQuery(query url.Values) string {
+	sorted := make(url.Values, len(query))
+	for key, values := range query {
+		values = append([]string(nil), values...)
+		sort.Strings(values)
+		sorted[key] = values
+	}
+	return normalizeQuery(sorted)
+}
@@ -6,10 +6,9 @@ import (
 	"net/http"
 )
 
-func calculateAWSv4Signature(region string, request *http.Request, credentials awsCredentials) string {
-	signer := newV4Signer("s3", region, request.Header.Get("X-Amz-Content-Sha256"), request, credentials)
-	signature := signer.calculateSignature()
-	return signature.task4_AuthorizationHeader
+func calculateAWSv4Signature(service, region string, request *http.Request, credentials awsCredentials) v4Signature {
+	signer := newV4Signer(service, region, request.Header.Get("X-Amz-Content-Sha256"), request, credentials)
+	return signer.calculateSignature()
 }
 
 type v4Signer struct {
@@ -68,13 +67,8 @@ func (this *v4Signer) task2_StringToSign(canonicalRequest string) string {
 
 // TASK 3: https://docs.aws.amazon.com/general/latest/gr/sigv4-calculate-signature.html
 func (this *v4Signer) task3_IntermediateSignature(stringToSign string) string {
-	signingKey := []byte(awsV4SignatureInitializationString + this.keys.SecretAccessKey)
-	signingKey = hmacSHA256(signingKey, this.data.date)
-	signingKey = hmacSHA256(signingKey, this.data.region)
-	signingKey = hmacSHA256(signingKey, this.data.service)
-	signingKey = hmacSHA256(signingKey, awsV4CredentialScopeTerminationString)
-	signingKey = hmacSHA256(signingKey, stringToSign)
-	return hex.EncodeToString(signingKey)
+	signingKey := deriveSigningKeyV4(this.keys.SecretAccessKey, this.data.date, this.data.region, this.data.service)
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
 }
 
 // TASK 4: https://docs.aws.amazon.com/general/latest/gr/sigv4-add-signature-to-request.html
@@ -88,8 +82,20 @@ func (this *v4Signer) task4_AuthorizationHeader(signature string) string {
 	)
 }
 
+func deriveSigningKeyV4(secret, date, region, service string) []byte {
+	signingKey := []byte(awsV4SignatureInitializationString + secret)
+	signingKey = hmacSHA256(signingKey, date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	return hmacSHA256(signingKey, awsV4CredentialScopeTerminationString)
+}
+
 const (
 	awsV4SignatureInitializationString    = "AWS4"
 	awsV4CredentialScopeTerminationString = "aws4_request"
 	awsV4SignatureAlgorithm               = "AWS4-HMAC-SHA256"
+	awsV4UnsignedPayload                  = "UNSIGNED-PAYLOAD"
+
+	awsS3Service  = "s3"
+	awsSTSService = "sts"
 )
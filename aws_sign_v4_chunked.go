@@ -0,0 +1,226 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// v4ChunkedReader frames the payload according to the aws-chunked encoding,
+// signing each chunk with a signature that chains from the previous one
+// (beginning with the seed signature found in the Authorization header).
+// Only a single chunk is ever held in memory.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+type v4ChunkedReader struct {
+	source     io.Reader
+	signingKey []byte
+	timestamp  string
+	scope      string
+	signature  string
+
+	chunk    []byte
+	frame    *bytes.Buffer
+	finished bool
+}
+
+func newV4ChunkedReader(source io.Reader, chunkSize int, signingKey []byte, timestamp, scope, seedSignature string) *v4ChunkedReader {
+	return &v4ChunkedReader{
+		source:     source,
+		signingKey: signingKey,
+		timestamp:  timestamp,
+		scope:      scope,
+		signature:  seedSignature,
+		chunk:      make([]byte, chunkSize),
+		frame:      bytes.NewBuffer(make([]byte, 0, chunkSize+chunkFrameOverhead(chunkSize))),
+	}
+}
+
+func (this *v4ChunkedReader) Read(buffer []byte) (int, error) {
+	for this.frame.Len() == 0 {
+		if this.finished {
+			return 0, io.EOF
+		}
+		if err := this.readNextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	return this.frame.Read(buffer)
+}
+
+func (this *v4ChunkedReader) readNextChunk() error {
+	length, err := io.ReadFull(this.source, this.chunk)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	this.writeFrame(this.chunk[:length])
+	this.finished = length == 0 // the final, zero-length chunk terminates the payload
+	return nil
+}
+
+func (this *v4ChunkedReader) writeFrame(chunk []byte) {
+	this.signature = this.signChunk(chunk)
+	this.frame.Reset()
+	this.frame.WriteString(strconv.FormatInt(int64(len(chunk)), 16))
+	this.frame.WriteString(awsV4ChunkSignaturePrefix)
+	this.frame.WriteString(this.signature)
+	this.frame.WriteString("\r\n")
+	this.frame.Write(chunk)
+	this.frame.WriteString("\r\n")
+}
+
+func (this *v4ChunkedReader) signChunk(chunk []byte) string {
+	return signChunkV4(this.signingKey, this.timestamp, this.scope, this.signature, chunk)
+}
+
+// signChunkV4 signs a chunk of the aws-chunked encoding, chaining from the signature of the previous chunk.
+func signChunkV4(signingKey []byte, timestamp, scope, previousSignature string, chunk []byte) string {
+	stringToSign := join("\n",
+		awsV4ChunkedPayloadAlgorithm,
+		timestamp,
+		scope,
+		previousSignature,
+		hashSHA256(nil),
+		hashSHA256(chunk),
+	)
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+// v4ChunkedVerifier checks the signature of each chunk of an aws-chunked payload as it is read, chaining
+// from the seed signature (already verified) in the Authorization header. The payload is passed through
+// as received, one frame at a time, and fails to read once a chunk is found to have been tampered with
+// or the payload ends without the final, zero-length chunk.
+type v4ChunkedVerifier struct {
+	body          io.ReadCloser
+	source        *bufio.Reader
+	signingKey    []byte
+	timestamp     string
+	scope         string
+	signature     string
+	decodedLength int64 // as stated by the X-Amz-Decoded-Content-Length header, or -1
+	decoded       int64
+
+	frame    bytes.Buffer
+	finished bool
+}
+
+func newV4ChunkedVerifier(body io.ReadCloser, decodedLength int64, signingKey []byte, timestamp, scope, seedSignature string) *v4ChunkedVerifier {
+	return &v4ChunkedVerifier{
+		body:          body,
+		source:        bufio.NewReader(body),
+		signingKey:    signingKey,
+		timestamp:     timestamp,
+		scope:         scope,
+		signature:     seedSignature,
+		decodedLength: decodedLength,
+	}
+}
+
+func (this *v4ChunkedVerifier) Read(buffer []byte) (int, error) {
+	for this.frame.Len() == 0 {
+		if this.finished {
+			return 0, io.EOF
+		}
+		if err := this.readNextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	return this.frame.Read(buffer)
+}
+
+func (this *v4ChunkedVerifier) readNextFrame() error {
+	header, err := this.source.ReadSlice('\n')
+	if err != nil {
+		return newChunkedPayloadError("a chunk header couldn't be read", err)
+	}
+	size, signature, err := parseChunkHeader(header)
+	if err != nil {
+		return err
+	}
+	this.frame.Reset()
+	this.frame.Write(header)
+
+	chunk := make([]byte, size+len("\r\n"))
+	if _, err = io.ReadFull(this.source, chunk); err != nil {
+		return newChunkedPayloadError("a chunk couldn't be read", err)
+	}
+	if !bytes.HasSuffix(chunk, []byte("\r\n")) {
+		return newChunkedPayloadError("a chunk isn't terminated by CRLF", nil)
+	}
+	chunk = chunk[:size]
+	if expected := signChunkV4(this.signingKey, this.timestamp, this.scope, this.signature, chunk); !hmac.Equal([]byte(expected), []byte(signature)) {
+		return &VerificationError{Component: "Payload", Reason: "the signature of chunk " + strconv.Quote(signature) + " doesn't match " + strconv.Quote(expected)}
+	}
+	this.signature = signature
+	this.decoded += int64(size)
+	this.frame.Write(chunk)
+	this.frame.WriteString("\r\n")
+
+	if size > 0 {
+		return nil
+	}
+	this.finished = true // the final, zero-length chunk terminates the payload
+	if _, err = this.source.ReadByte(); err != io.EOF {
+		return newChunkedPayloadError("the payload continues after the final chunk", nil)
+	}
+	if this.decodedLength >= 0 && this.decoded != this.decodedLength {
+		return newChunkedPayloadError("the decoded payload is "+formatInt64(this.decoded)+" bytes, not "+formatInt64(this.decodedLength), nil)
+	}
+	return nil
+}
+
+func (this *v4ChunkedVerifier) Close() error { return this.body.Close() }
+
+// parseChunkHeader parses: hex(chunk-size);chunk-signature=<signature>\r\n
+func parseChunkHeader(header []byte) (size int, signature string, err error) {
+	line, found := strings.CutSuffix(string(header), "\r\n")
+	if !found {
+		return 0, "", newChunkedPayloadError("a chunk header isn't terminated by CRLF", nil)
+	}
+	hexSize, signature, found := strings.Cut(line, awsV4ChunkSignaturePrefix)
+	if !found {
+		return 0, "", newChunkedPayloadError("a chunk header has no signature", nil)
+	}
+	parsed, err := strconv.ParseUint(hexSize, 16, 32)
+	if err != nil || parsed > maxChunkSize {
+		return 0, "", newChunkedPayloadError("a chunk header has an invalid size "+strconv.Quote(hexSize), err)
+	}
+	return int(parsed), signature, nil
+}
+
+func newChunkedPayloadError(reason string, err error) error {
+	return &VerificationError{Component: "Payload", Reason: reason, Err: err}
+}
+
+// chunkedContentLength calculates the length of the aws-chunked encoding
+// of a payload of the provided (decoded) length.
+func chunkedContentLength(decodedLength int64, chunkSize int) int64 {
+	fullChunks := decodedLength / int64(chunkSize)
+	remainder := int(decodedLength % int64(chunkSize))
+
+	length := fullChunks * int64(chunkSize+chunkFrameOverhead(chunkSize))
+	if remainder > 0 {
+		length += int64(remainder + chunkFrameOverhead(remainder))
+	}
+	return length + int64(chunkFrameOverhead(0))
+}
+
+// chunkFrameOverhead is the length of everything in a chunk's frame but the chunk itself:
+// hex(chunk-size) + ";chunk-signature=" + signature + \r\n + chunk-data + \r\n
+func chunkFrameOverhead(chunkSize int) int {
+	return len(strconv.FormatInt(int64(chunkSize), 16)) +
+		len(awsV4ChunkSignaturePrefix) + hex.EncodedLen(32) + len("\r\n") + len("\r\n")
+}
+
+const (
+	awsV4StreamingPayload        = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	awsV4ChunkedPayloadAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	awsV4ChunkSignaturePrefix    = ";chunk-signature="
+	awsChunkedContentEncoding    = "aws-chunked"
+
+	defaultChunkSize = 64 * 1024
+	maxChunkSize     = 16 * 1024 * 1024 // the largest chunk a verifier will hold in memory
+)
@@ -0,0 +1,50 @@
+package s3
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestV4ChunkedReaderFixture(t *testing.T) {
+	gunit.Run(new(V4ChunkedReaderFixture), t)
+}
+
+// See: https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html (Example: PUT Object)
+type V4ChunkedReaderFixture struct {
+	*gunit.Fixture
+	payload string
+	reader  *v4ChunkedReader
+}
+
+func (this *V4ChunkedReaderFixture) Setup() {
+	this.payload = strings.Repeat("a", 66560)
+	signingKey := deriveSigningKeyV4("wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", "20130524", "us-east-1", "s3")
+	this.reader = newV4ChunkedReader(strings.NewReader(this.payload), 64*1024, signingKey,
+		"20130524T000000Z", "20130524/us-east-1/s3/aws4_request",
+		"4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9")
+}
+
+func (this *V4ChunkedReaderFixture) TestChunkSignatures() {
+	all, err := ioutil.ReadAll(this.reader)
+	this.So(err, should.BeNil)
+
+	expected := "10000;chunk-signature=ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648\r\n" +
+		this.payload[:65536] + "\r\n" +
+		"400;chunk-signature=0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497\r\n" +
+		this.payload[65536:] + "\r\n" +
+		"0;chunk-signature=b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9\r\n" +
+		"\r\n"
+	this.So(string(all), should.Equal, expected)
+}
+
+func (this *V4ChunkedReaderFixture) TestEncodedContentLength() {
+	all, _ := ioutil.ReadAll(this.reader)
+	this.So(chunkedContentLength(66560, 64*1024), should.Equal, 66824)
+	this.So(chunkedContentLength(66560, 64*1024), should.Equal, len(all))
+	this.So(chunkedContentLength(64*1024, 64*1024), should.Equal, 65626+86)
+	this.So(chunkedContentLength(0, 64*1024), should.Equal, 86)
+}
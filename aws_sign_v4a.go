@@ -0,0 +1,162 @@
+package s3
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// v4aSigner calculates SigV4A (multi-region) signatures, which differ from those of v4Signer in the algorithm,
+// a credential scope without a region (the X-Amz-Region-Set header states the regions instead), and an ECDSA
+// signature with a key derived from the credentials in place of the HMAC signature.
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html
+type v4aSigner struct {
+	*v4Signer
+}
+
+func newV4aSigner(service, bodyDigest string, request *http.Request, credentials awsCredentials) *v4aSigner {
+	return &v4aSigner{v4Signer: newV4Signer(service, "", bodyDigest, request, credentials)}
+}
+
+func (this *v4aSigner) calculateSignature() (v4Signature, error) {
+	task1 := this.task1_CanonicalRequest()
+	task2 := this.task2_StringToSign(task1)
+	task3, err := this.task3_Signature(task2)
+	if err != nil {
+		return v4Signature{}, err
+	}
+	task4 := this.task4_AuthorizationHeader(task3)
+	return v4Signature{
+		task1_CanonicalRequest:      task1,
+		task2_StringToSign:          task2,
+		task3_IntermediateSignature: task3,
+		task4_AuthorizationHeader:   task4,
+	}, nil
+}
+
+func (this *v4aSigner) task2_StringToSign(canonicalRequest string) string {
+	return join("\n",
+		awsV4ASignatureAlgorithm,
+		this.data.timestamp,
+		v4aCredentialScope(this.data.date, this.data.service),
+		hashSHA256([]byte(canonicalRequest)),
+	)
+}
+
+func (this *v4aSigner) task3_Signature(stringToSign string) (string, error) {
+	return signV4A(this.keys, stringToSign)
+}
+
+func (this *v4aSigner) task4_AuthorizationHeader(signature string) string {
+	return fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsV4ASignatureAlgorithm,
+		this.keys.AccessKeyID,
+		v4aCredentialScope(this.data.date, this.data.service),
+		this.data.signedHeaders,
+		signature,
+	)
+}
+
+func (this *v4aSigner) signingDetails(signature v4Signature) SigningDetails {
+	details := this.v4Signer.signingDetails(signature)
+	details.CredentialScope = v4aCredentialScope(this.data.date, this.data.service)
+	return details
+}
+
+func v4aCredentialScope(date, service string) string {
+	return join("/", date, service, awsV4CredentialScopeTerminationString)
+}
+
+// signV4A signs the SHA-256 digest of the string to sign with the ECDSA key derived from the credentials
+// and encodes the (ASN.1 DER) signature as hex. Unlike HMAC signatures, ECDSA signatures vary every time.
+func signV4A(credentials awsCredentials, stringToSign string) (string, error) {
+	key, err := deriveSigningKeyV4A(credentials.AccessKeyID, credentials.SecretAccessKey)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(signature), nil
+}
+
+// deriveSigningKeyV4A derives the P-256 private key of the credentials with the HMAC-SHA256 counter-mode
+// KDF of NIST SP 800-108, repeating with an incremented (single byte) counter appended to the access key
+// ID until the candidate is less than n-2 (where n is the order of the curve), then adding 1.
+func deriveSigningKeyV4A(accessKeyID, secret string) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	nMinusTwo := new(big.Int).Sub(curve.Params().N, big.NewInt(2))
+	inputKey := []byte(awsV4ASignatureInitializationString + secret)
+
+	for counter := 1; counter <= 0xFF; counter++ {
+		context := append([]byte(accessKeyID), byte(counter))
+		candidate := new(big.Int).SetBytes(hmacKeyDerivationSHA256(inputKey, []byte(awsV4ASignatureAlgorithm), context, 256))
+		if candidate.Cmp(nMinusTwo) >= 0 {
+			continue
+		}
+		d := candidate.Add(candidate, big.NewInt(1))
+		public, err := ecdhPublicKey(d)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PrivateKey{D: d}
+		key.PublicKey.Curve = curve
+		key.PublicKey.X = new(big.Int).SetBytes(public[1:33])
+		key.PublicKey.Y = new(big.Int).SetBytes(public[33:])
+		return key, nil
+	}
+	return nil, errSigningKeyV4AExhausted
+}
+
+// ecdhPublicKey is the uncompressed (0x04 || X || Y) public key of the P-256 private key d.
+func ecdhPublicKey(d *big.Int) ([]byte, error) {
+	private, err := ecdh.P256().NewPrivateKey(d.FillBytes(make([]byte, 32)))
+	if err != nil {
+		return nil, err
+	}
+	return private.PublicKey().Bytes(), nil
+}
+
+// hmacKeyDerivationSHA256 is the KDF in counter mode (with HMAC-SHA256 as the PRF) of NIST SP 800-108:
+// K(i) = HMAC(key, [i]₃₂ || label || 0x00 || context || [bits]₃₂), concatenated and truncated to bits.
+func hmacKeyDerivationSHA256(key, label, context []byte, bits int) []byte {
+	var output []byte
+	for i := uint32(1); len(output)*8 < bits; i++ {
+		mac := hmac.New(sha256.New, key)
+		_ = binary.Write(mac, binary.BigEndian, i)
+		mac.Write(label)
+		mac.Write([]byte{0x00})
+		mac.Write(context)
+		_ = binary.Write(mac, binary.BigEndian, uint32(bits))
+		output = mac.Sum(output)
+	}
+	return output[:bits/8]
+}
+
+// regionSetV4A is the value of the X-Amz-Region-Set header (or query parameter), which defaults to all regions.
+func regionSetV4A(regions []string) string {
+	if len(regions) == 0 {
+		return "*"
+	}
+	return strings.Join(regions, ",")
+}
+
+var errSigningKeyV4AExhausted = errors.New("could not derive a SigV4A signing key from the credentials")
+
+const (
+	awsV4ASignatureInitializationString = "AWS4A"
+	awsV4ASignatureAlgorithm            = "AWS4-ECDSA-P256-SHA256"
+	headerRegionSet                     = "X-Amz-Region-Set"
+)
@@ -7,9 +7,20 @@ import (
 )
 
 func canonicalAndSignedHeaders(original http.Header) (canonical, signed string) {
+	return canonicalizeHeaders(original, false)
+}
+
+// canonicalizeHeaders is like canonicalAndSignedHeaders but may include the port of the Host header,
+// which S3-compatible services listening on non-standard ports (MinIO, Ceph, etc.) expect to be signed.
+func canonicalizeHeaders(original http.Header, includeHostPort bool) (canonical, signed string) {
+	return canonicalizeSelectedHeaders(original, headerEligibleForSigning, includeHostPort)
+}
+
+// canonicalizeSelectedHeaders is like canonicalizeHeaders but signs only the headers accepted by eligible.
+func canonicalizeSelectedHeaders(original http.Header, eligible func(key string) bool, includeHostPort bool) (canonical, signed string) {
 	lowercaseKeys := map[string]string{} // map[lowercase]original
 	for key := range original {
-		if headerEligibleForSigning(key) {
+		if eligible(key) {
 			lowercaseKeys[strings.ToLower(key)] = key
 		}
 	}
@@ -25,7 +36,7 @@ func canonicalAndSignedHeaders(original http.Header) (canonical, signed string)
 		titleKey := lowercaseKeys[lowerKey]
 		var values []string
 		for _, value := range original[titleKey] {
-			if titleKey == "Host" && strings.Contains(value, ":") {
+			if titleKey == "Host" && !includeHostPort && strings.Contains(value, ":") {
 				value = strings.Split(value, ":")[0] // AWS does not include port in signing request.
 			}
 			values = append(values, trimHeaderValue(value))
@@ -0,0 +1,109 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SigningDetails reports the intermediate values of a signature, which S3 also reports (as CanonicalRequest
+// and StringToSign) when it rejects a request with SignatureDoesNotMatch. See the SigningTrace option.
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-troubleshooting.html
+type SigningDetails struct {
+	CanonicalRequest string
+	StringToSign     string
+	SignedHeaders    string
+	CredentialScope  string
+	Signature        string
+}
+
+func (this *v4Signer) signingDetails(signature v4Signature) SigningDetails {
+	return SigningDetails{
+		CanonicalRequest: signature.task1_CanonicalRequest,
+		StringToSign:     signature.task2_StringToSign,
+		SignedHeaders:    this.data.signedHeaders,
+		CredentialScope:  this.data.credentialScope(),
+		Signature:        signature.task3_IntermediateSignature,
+	}
+}
+
+// Diff describes (one per line) each part of the canonical request and string to sign that differs from
+// those reported by the *ResponseError (for SignatureDoesNotMatch) in err, such as "header x-amz-date:
+// signed "20130524T000000Z" but S3 expected "20130524T000001Z"". It's blank if err reports neither.
+func (this SigningDetails) Diff(err error) string {
+	var failure *ResponseError
+	if !errors.As(err, &failure) || (len(failure.CanonicalRequest) == 0 && len(failure.StringToSign) == 0) {
+		return ""
+	}
+	var differences []string
+	if len(failure.CanonicalRequest) > 0 {
+		differences = diffSigningParts(differences,
+			canonicalRequestParts(this.CanonicalRequest), canonicalRequestParts(failure.CanonicalRequest))
+	}
+	if len(failure.StringToSign) > 0 {
+		differences = diffSigningParts(differences,
+			stringToSignParts(this.StringToSign), stringToSignParts(failure.StringToSign))
+	}
+	return strings.Join(differences, "\n")
+}
+
+type signingPart struct{ name, value string }
+
+func diffSigningParts(differences []string, signed, expected []signingPart) []string {
+	expectedValues := make(map[string]string, len(expected))
+	for _, part := range expected {
+		expectedValues[part.name] = part.value
+	}
+	for _, part := range signed {
+		value, found := expectedValues[part.name]
+		delete(expectedValues, part.name)
+		if !found {
+			differences = append(differences, fmt.Sprintf("%s: signed %q but S3 expected none", part.name, part.value))
+		} else if value != part.value {
+			differences = append(differences, fmt.Sprintf("%s: signed %q but S3 expected %q", part.name, part.value, value))
+		}
+	}
+	for _, part := range expected {
+		if value, found := expectedValues[part.name]; found {
+			differences = append(differences, fmt.Sprintf("%s: signed none but S3 expected %q", part.name, value))
+		}
+	}
+	return differences
+}
+
+// canonicalRequestParts names the lines of a canonical request: the method, path, query, each header, the
+// signed headers, and the payload (hash). S3 reports the headers it expected to be signed, and the ordering
+// of the lines makes it possible to tell them apart even when the number of headers differs.
+func canonicalRequestParts(canonicalRequest string) []signingPart {
+	lines := strings.Split(canonicalRequest, "\n")
+	if len(lines) < 6 {
+		return []signingPart{{name: "canonical request", value: canonicalRequest}}
+	}
+	parts := []signingPart{
+		{name: "method", value: lines[0]},
+		{name: "path", value: lines[1]},
+		{name: "query", value: lines[2]},
+	}
+	for _, line := range lines[3 : len(lines)-3] {
+		name, value, _ := strings.Cut(line, ":")
+		parts = append(parts, signingPart{name: "header " + name, value: value})
+	}
+	return append(parts,
+		signingPart{name: "signed headers", value: lines[len(lines)-2]},
+		signingPart{name: "payload", value: lines[len(lines)-1]},
+	)
+}
+
+// stringToSignParts names the lines of a string to sign, except for the hash of
+// the canonical request (which differs along with any part of the canonical request).
+func stringToSignParts(stringToSign string) []signingPart {
+	lines := strings.Split(stringToSign, "\n")
+	if len(lines) != 4 {
+		return []signingPart{{name: "string to sign", value: stringToSign}}
+	}
+	return []signingPart{
+		{name: "algorithm", value: lines[0]},
+		{name: "timestamp", value: lines[1]},
+		{name: "credential scope", value: lines[2]},
+	}
+}
@@ -0,0 +1,133 @@
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// stsInput gathers the (Endpoint and Region) options that locate the STS service. It deliberately
+// skips the defaults of applyOptions (like ambient credentials) that don't apply to STS itself.
+func stsInput(options []Option) *inputModel {
+	in := newBareInput(options)
+	if len(in.region) == 0 {
+		in.region = environmentRegion()
+	}
+	return in
+}
+
+// stsEndpoint prefers an explicit endpoint, then $AWS_ENDPOINT_URL_STS, then the regional (or global) endpoint.
+func (this *inputModel) stsEndpoint() string {
+	if len(this.endpoint) > 0 {
+		return this.endpoint
+	}
+	if endpoint := os.Getenv(envSTSEndpoint); len(endpoint) > 0 {
+		return endpoint
+	}
+	if len(this.region) == 0 {
+		return "https://sts.amazonaws.com"
+	}
+	return "https://sts." + this.region + ".amazonaws.com"
+}
+
+// newSTSRequest creates a POST request for the STS query API action with the form parameters.
+func newSTSRequest(endpoint, action string, parameters url.Values) (*http.Request, error) {
+	form := url.Values{}
+	for key, values := range parameters {
+		form[key] = values
+	}
+	form.Set("Action", action)
+	form.Set("Version", stsAPIVersion)
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	return request, nil
+}
+
+// signSTSRequest signs the request (with Signature Version 4) using the credentials of the input.
+// Without a region, the request is signed for us-east-1 (as with the global endpoint).
+func (this *inputModel) signSTSRequest(request *http.Request) {
+	region := this.region
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+	if request.URL.Path == "" {
+		request.URL.Path = "/"
+	}
+	payload := readAndReplaceBody(request)
+	setHeader(request, "Host", request.URL.Host)
+	setHeader(request, "X-Amz-Security-Token", this.credential().SecurityToken)
+	setHeader(request, "X-Amz-Content-Sha256", hashSHA256(payload))
+	setHeader(request, "X-Amz-Date", this.timestampV4())
+	signature := calculateAWSv4Signature(awsSTSService, region, request, this.credential())
+	request.Header.Set("Authorization", signature.task4_AuthorizationHeader)
+}
+
+// doSTSRequest sends the request and decodes the credentials from its response.
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_Credentials.html
+func doSTSRequest(client *http.Client, request *http.Request, action string) (credentials awsCredentials, err error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return credentials, err
+	}
+	defer closeHandle(response.Body)
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return credentials, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return credentials, newSTSError(action, response.StatusCode, body)
+	}
+
+	var document struct {
+		Results []struct {
+			Credentials struct {
+				AccessKeyID     string    `xml:"AccessKeyId"`
+				SecretAccessKey string    `xml:"SecretAccessKey"`
+				SessionToken    string    `xml:"SessionToken"`
+				Expiration      time.Time `xml:"Expiration"`
+			} `xml:"Credentials"`
+		} `xml:",any"`
+	}
+	if err = xml.Unmarshal(body, &document); err != nil {
+		return credentials, fmt.Errorf("malformed sts %s response: %w", action, err)
+	}
+	for _, result := range document.Results {
+		credentials.AccessKeyID = result.Credentials.AccessKeyID
+		credentials.SecretAccessKey = result.Credentials.SecretAccessKey
+		credentials.SecurityToken = result.Credentials.SessionToken
+		credentials.Expiration = result.Credentials.Expiration
+		if credentials.complete() {
+			return credentials, nil
+		}
+	}
+	return credentials, fmt.Errorf("sts %s response contained no credentials", action)
+}
+
+func newSTSError(action string, statusCode int, body []byte) error {
+	var document struct {
+		Error struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		} `xml:"Error"`
+	}
+	if err := xml.Unmarshal(body, &document); err != nil || len(document.Error.Code) == 0 {
+		document.Error.Code = http.StatusText(statusCode)
+	}
+	return fmt.Errorf("sts responded to %s with %d %s: %s", action, statusCode, document.Error.Code, document.Error.Message)
+}
+
+const (
+	envSTSEndpoint = "AWS_ENDPOINT_URL_STS"
+
+	stsAPIVersion = "2011-06-15"
+)
@@ -0,0 +1,352 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Verify authenticates a request (as received by a server) signed by way of AWS Signature Version 4, either
+// with the Authorization header or with the query string (as with a presigned URL). The secret access key of
+// the signer is supplied by lookupSecret. Verify recalculates the signature and checks that the request was
+// signed within 15 minutes of now (see the Timestamp option) and, if presigned, hasn't expired. Signatures
+// must be scoped to the Service (by default "s3") and, if specified, the Region. Any failure is a
+// *VerificationError.
+//
+// A payload stated by the X-Amz-Content-Sha256 header is checked as it is read (after Verify returns), such
+// that reading the end of the body fails with a *VerificationError should the checksum differ. Otherwise the
+// payload is read (and replaced) to calculate its checksum, so callers should limit the size of the body
+// (see http.MaxBytesReader); a failure to read it is reported as a *VerificationError. The chunk signatures of
+// a streaming (aws-chunked) payload are likewise checked as it is read, though the body is passed through as
+// received (still encoded); streaming payloads other than STREAMING-AWS4-HMAC-SHA256-PAYLOAD are rejected.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+func Verify(request *http.Request, lookupSecret func(accessKeyID string) (string, error), options ...Option) error {
+	in := newBareInput(options)
+	if len(in.service) == 0 {
+		in.service = awsS3Service
+	}
+
+	authorization, err := parseV4Authorization(request)
+	if err != nil {
+		return err
+	}
+	if err = authorization.checkScope(in.region, in.service); err != nil {
+		return err
+	}
+	if err = authorization.checkTime(in.now); err != nil {
+		return err
+	}
+	secret, err := lookupSecret(authorization.accessKeyID)
+	if err != nil {
+		return &VerificationError{Component: "Credential", Reason: "unknown access key " + authorization.accessKeyID, Err: err}
+	}
+	return newRequestVerifier(request, authorization).verify(secret)
+}
+
+// VerificationError describes the reason Verify rejected a request, naming the Component at fault:
+// "Authorization" (the header or the query parameters as a whole), "X-Amz-Algorithm", "Credential",
+// "X-Amz-Date", "X-Amz-Expires", "SignedHeaders", "X-Amz-Content-Sha256", "Payload", or "Signature". When the signature
+// doesn't match, CanonicalRequest and StringToSign are those calculated for comparison with the signer's own.
+type VerificationError struct {
+	Component        string
+	Reason           string
+	CanonicalRequest string
+	StringToSign     string
+	Err              error
+}
+
+func (this *VerificationError) Error() string {
+	if this.Err != nil {
+		return fmt.Sprintf("signature verification failed: %s: %s: %s", this.Component, this.Reason, this.Err)
+	}
+	return fmt.Sprintf("signature verification failed: %s: %s", this.Component, this.Reason)
+}
+
+func (this *VerificationError) Unwrap() error { return this.Err }
+
+// v4Authorization holds the signature (and its scope) from the Authorization header or the query string.
+type v4Authorization struct {
+	accessKeyID   string
+	date          string
+	region        string
+	service       string
+	timestamp     time.Time
+	expires       time.Duration // only for query (presigned) signatures
+	signedHeaders []string
+	signature     string
+	query         bool
+}
+
+func parseV4Authorization(request *http.Request) (*v4Authorization, error) {
+	if header := request.Header.Get("Authorization"); len(header) > 0 {
+		return parseV4AuthorizationHeader(header, request.Header.Get(headerDate))
+	}
+	if query := request.URL.Query(); len(query.Get(headerSignature)) > 0 {
+		return parseV4AuthorizationQuery(query)
+	}
+	return nil, &VerificationError{Component: "Authorization", Reason: "neither the Authorization header nor the X-Amz-Signature query parameter is present"}
+}
+
+// parseV4AuthorizationHeader parses: AWS4-HMAC-SHA256 Credential=<scope>, SignedHeaders=<names>, Signature=<signature>
+func parseV4AuthorizationHeader(header, timestamp string) (*v4Authorization, error) {
+	algorithm, parameters, _ := strings.Cut(header, " ")
+	if algorithm != awsV4SignatureAlgorithm {
+		return nil, &VerificationError{Component: "X-Amz-Algorithm", Reason: "unsupported algorithm " + strconv.Quote(algorithm)}
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Split(parameters, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[name] = value
+	}
+	return newV4Authorization(fields["Credential"], timestamp, fields["SignedHeaders"], fields["Signature"], "", false)
+}
+
+func parseV4AuthorizationQuery(query url.Values) (*v4Authorization, error) {
+	if algorithm := query.Get(headerAlgorithm); algorithm != awsV4SignatureAlgorithm {
+		return nil, &VerificationError{Component: "X-Amz-Algorithm", Reason: "unsupported algorithm " + strconv.Quote(algorithm)}
+	}
+	return newV4Authorization(query.Get(headerCredential), query.Get(headerDate),
+		query.Get(headerSignedHeaders), query.Get(headerSignature), query.Get(headerExpires), true)
+}
+
+func newV4Authorization(credential, timestamp, signedHeaders, signature, expires string, query bool) (*v4Authorization, error) {
+	if len(credential) == 0 || len(signedHeaders) == 0 || len(signature) == 0 {
+		return nil, &VerificationError{Component: "Authorization", Reason: "the Credential, SignedHeaders, and Signature are required"}
+	}
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 || scope[4] != awsV4CredentialScopeTerminationString {
+		return nil, &VerificationError{Component: "Credential", Reason: "malformed credential scope " + strconv.Quote(credential)}
+	}
+	parsed, err := time.Parse(timeFormatV4, timestamp)
+	if err != nil {
+		return nil, &VerificationError{Component: "X-Amz-Date", Reason: "malformed timestamp " + strconv.Quote(timestamp), Err: err}
+	}
+	if scope[1] != timestampDateV4(timestamp) {
+		return nil, &VerificationError{Component: "Credential", Reason: "the credential scope date " + scope[1] + " differs from the X-Amz-Date " + timestamp}
+	}
+	authorization := &v4Authorization{
+		accessKeyID:   scope[0],
+		date:          scope[1],
+		region:        scope[2],
+		service:       scope[3],
+		timestamp:     parsed,
+		signedHeaders: strings.Split(signedHeaders, ";"),
+		signature:     signature,
+		query:         query,
+	}
+	if query {
+		seconds, err := strconv.Atoi(expires)
+		if err != nil || seconds < 1 || seconds > maxPresignedExpiration {
+			return nil, &VerificationError{Component: "X-Amz-Expires", Reason: "expected 1 to 604800 seconds, not " + strconv.Quote(expires)}
+		}
+		authorization.expires = time.Duration(seconds) * time.Second
+	}
+	return authorization, nil
+}
+
+// checkScope rejects signatures for another service or (if one is expected) region.
+func (this *v4Authorization) checkScope(region, service string) error {
+	if this.service != service {
+		return &VerificationError{Component: "Credential", Reason: "the credential scope is for the service " + strconv.Quote(this.service) + ", not " + strconv.Quote(service)}
+	}
+	if len(region) > 0 && this.region != region {
+		return &VerificationError{Component: "Credential", Reason: "the credential scope is for the region " + strconv.Quote(this.region) + ", not " + strconv.Quote(region)}
+	}
+	return nil
+}
+
+// checkTime rejects signatures from too far in the past (or future) and expired presigned URLs.
+func (this *v4Authorization) checkTime(now time.Time) error {
+	if this.timestamp.Sub(now) > maxClockSkew {
+		return &VerificationError{Component: "X-Amz-Date", Reason: "the request was signed in the future at " + this.timestamp.Format(time.RFC3339)}
+	}
+	if this.query {
+		if expiration := this.timestamp.Add(this.expires); !now.Before(expiration) {
+			return &VerificationError{Component: "X-Amz-Expires", Reason: "the request expired at " + expiration.Format(time.RFC3339)}
+		}
+	} else if now.Sub(this.timestamp) > maxClockSkew {
+		return &VerificationError{Component: "X-Amz-Date", Reason: "the request was signed more than 15 minutes ago at " + this.timestamp.Format(time.RFC3339)}
+	}
+	return nil
+}
+
+type requestVerifier struct {
+	request       *http.Request
+	authorization *v4Authorization
+	signHeaders   map[string]bool
+}
+
+func newRequestVerifier(request *http.Request, authorization *v4Authorization) *requestVerifier {
+	signHeaders := make(map[string]bool)
+	for _, name := range authorization.signedHeaders {
+		signHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+	return &requestVerifier{request: request, authorization: authorization, signHeaders: signHeaders}
+}
+
+func (this *requestVerifier) verify(secret string) error {
+	headers, err := this.signedHeaders()
+	if err != nil {
+		return err
+	}
+	bodyDigest, err := this.bodyDigest()
+	if err != nil {
+		return err
+	}
+	query := this.request.URL.Query()
+	query.Del(headerSignature)
+
+	signer := &v4Signer{
+		keys: awsCredentials{AccessKeyID: this.authorization.accessKeyID, SecretAccessKey: secret},
+		data: &v4RequestData{
+			service:    this.authorization.service,
+			region:     this.authorization.region,
+			method:     this.request.Method,
+			urlPath:    canonicalURI(this.authorization.service, this.request.URL),
+			urlQuery:   canonicalQuery(query),
+			bodyDigest: bodyDigest,
+			timestamp:  this.authorization.timestamp.Format(timeFormatV4),
+			date:       this.authorization.date,
+		},
+	}
+
+	// Signers disagree about whether the port belongs in the Host header, so either is accepted.
+	var signature v4Signature
+	for _, includeHostPort := range []bool{true, false} {
+		signer.data.canonicalHeaders, signer.data.signedHeaders = canonicalizeSelectedHeaders(headers, this.eligible, includeHostPort)
+		signature = signer.calculateSignature()
+		if hmac.Equal([]byte(signature.task3_IntermediateSignature), []byte(this.authorization.signature)) {
+			return this.verifyChunks(bodyDigest, secret, signer.data)
+		}
+	}
+	return &VerificationError{
+		Component:        "Signature",
+		Reason:           "the signature doesn't match the one calculated from the canonical request",
+		CanonicalRequest: signature.task1_CanonicalRequest,
+		StringToSign:     signature.task2_StringToSign,
+	}
+}
+
+// verifyChunks arranges for the signature of each chunk of a streaming payload to be checked as it is read.
+func (this *requestVerifier) verifyChunks(bodyDigest, secret string, data *v4RequestData) error {
+	if bodyDigest != awsV4StreamingPayload {
+		return nil
+	}
+	if this.request.Body == nil || this.request.Body == http.NoBody {
+		return newChunkedPayloadError("the streaming payload is empty", nil)
+	}
+	decodedLength := int64(-1)
+	if header := this.request.Header.Get("X-Amz-Decoded-Content-Length"); len(header) > 0 {
+		length, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || length < 0 {
+			return &VerificationError{Component: "X-Amz-Decoded-Content-Length", Reason: "malformed length " + strconv.Quote(header), Err: err}
+		}
+		decodedLength = length
+	}
+	signingKey := deriveSigningKeyV4(secret, data.date, data.region, data.service)
+	this.request.Body = newV4ChunkedVerifier(this.request.Body, decodedLength, signingKey, data.timestamp, data.credentialScope(), this.authorization.signature)
+	return nil
+}
+
+func (this *requestVerifier) eligible(key string) bool { return this.signHeaders[key] }
+
+// signedHeaders are the request headers along with the Host header, all of which must have been signed.
+func (this *requestVerifier) signedHeaders() (http.Header, error) {
+	headers := this.request.Header.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	host := this.request.Host
+	if len(host) == 0 {
+		host = this.request.URL.Host
+	}
+	headers.Set("Host", host)
+
+	if !this.signHeaders["Host"] {
+		return nil, &VerificationError{Component: "SignedHeaders", Reason: "the Host header must be signed"}
+	}
+	for key := range this.signHeaders {
+		if _, found := headers[key]; !found {
+			return nil, &VerificationError{Component: "SignedHeaders", Reason: "the signed " + key + " header is absent"}
+		}
+	}
+	return headers, nil
+}
+
+// bodyDigest is the checksum of the payload that was signed, as stated by the X-Amz-Content-Sha256 header
+// (which the payload must match, once read, unless UNSIGNED-PAYLOAD) or calculated from it. The chunks of
+// a streaming payload are verified once the seed signature has been (see verifyChunks).
+func (this *requestVerifier) bodyDigest() (string, error) {
+	stated := this.request.Header.Get("X-Amz-Content-Sha256")
+	if this.authorization.query && this.authorization.service == awsS3Service && len(stated) == 0 {
+		return awsV4UnsignedPayload, nil
+	}
+	if stated == awsV4UnsignedPayload || stated == awsV4StreamingPayload {
+		return stated, nil
+	}
+	if strings.HasPrefix(stated, "STREAMING-") {
+		return "", &VerificationError{Component: "X-Amz-Content-Sha256", Reason: "the streaming payload " + strconv.Quote(stated) + " isn't supported"}
+	}
+	if this.request.Body == nil || this.request.Body == http.NoBody {
+		digest := hashSHA256(nil)
+		if len(stated) > 0 && stated != digest {
+			return "", newPayloadChecksumError(digest, stated)
+		}
+		return digest, nil
+	}
+	if len(stated) > 0 {
+		this.request.Body = newPayloadVerifier(this.request.Body, stated)
+		return stated, nil
+	}
+
+	payload, err := ioutil.ReadAll(this.request.Body)
+	_ = this.request.Body.Close()
+	if err != nil {
+		return "", &VerificationError{Component: "Payload", Reason: "the payload couldn't be read", Err: err}
+	}
+	this.request.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	return hashSHA256(payload), nil
+}
+
+// payloadVerifier calculates the checksum of the body as it is read, failing at the end if it isn't as stated.
+type payloadVerifier struct {
+	body   io.ReadCloser
+	hash   hash.Hash
+	stated string
+}
+
+func newPayloadVerifier(body io.ReadCloser, stated string) *payloadVerifier {
+	return &payloadVerifier{body: body, hash: sha256.New(), stated: stated}
+}
+
+func (this *payloadVerifier) Read(buffer []byte) (int, error) {
+	n, err := this.body.Read(buffer)
+	this.hash.Write(buffer[:n])
+	if err == io.EOF {
+		if digest := hex.EncodeToString(this.hash.Sum(nil)); digest != this.stated {
+			return n, newPayloadChecksumError(digest, this.stated)
+		}
+	}
+	return n, err
+}
+
+func (this *payloadVerifier) Close() error { return this.body.Close() }
+
+func newPayloadChecksumError(digest, stated string) error {
+	return &VerificationError{Component: "X-Amz-Content-Sha256", Reason: "the payload checksum is " + digest + ", not " + stated}
+}
+
+const (
+	maxClockSkew           = 15 * time.Minute
+	maxPresignedExpiration = 7 * 24 * 60 * 60 // seconds
+)
@@ -0,0 +1,109 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewWebIdentityCredentialsProvider creates a provider of the credentials of the role assumed (by way of
+// STS AssumeRoleWithWebIdentity) with the OIDC token in the file, as with IAM roles for service accounts
+// (IRSA) on EKS. A blank roleARN, tokenFile, or sessionName defers to $AWS_ROLE_ARN,
+// $AWS_WEB_IDENTITY_TOKEN_FILE, or $AWS_ROLE_SESSION_NAME, respectively. The Region and Endpoint
+// options locate the STS service. The credentials are cached until they are about to expire.
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRoleWithWebIdentity.html
+func NewWebIdentityCredentialsProvider(roleARN, tokenFile, sessionName string, options ...Option) CredentialsProvider {
+	return NewCachedCredentialsProvider(&webIdentityCredentialsProvider{
+		roleARN:     roleARN,
+		tokenFile:   tokenFile,
+		sessionName: sessionName,
+		options:     options,
+		client:      &http.Client{Timeout: time.Second * 5},
+	})
+}
+
+type webIdentityCredentialsProvider struct {
+	roleARN     string
+	tokenFile   string
+	sessionName string
+	options     []Option
+	client      *http.Client
+}
+
+func (this *webIdentityCredentialsProvider) Retrieve() (CredentialsValue, error) {
+	roleARN := valueOrEnvironment(this.roleARN, envRoleARN)
+	tokenFile := valueOrEnvironment(this.tokenFile, envWebIdentityTokenFile)
+	if len(roleARN) == 0 || len(tokenFile) == 0 {
+		return CredentialsValue{}, errWebIdentityNotConfigured
+	}
+
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return CredentialsValue{}, fmt.Errorf("could not read web identity token: %w", err)
+	}
+
+	in := stsInput(this.options)
+	sessionName := valueOrEnvironment(this.sessionName, envRoleSessionName)
+	if len(sessionName) == 0 {
+		sessionName = "s3-" + strconv.FormatInt(in.now.UnixNano(), 10)
+	}
+
+	request, err := newSTSRequest(in.stsEndpoint(), stsAssumeRoleWithWebIdentity, url.Values{
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	})
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+
+	credentials, err := doSTSRequest(this.client, request, stsAssumeRoleWithWebIdentity)
+	if err != nil {
+		return CredentialsValue{}, err
+	}
+	credentials.Source = SourceWebIdentity
+	return newCredentialsValue(credentials), nil
+}
+
+// IsExpired defers to the expiration of the credentials, which are always temporary.
+func (this *webIdentityCredentialsProvider) IsExpired() bool { return false }
+
+func valueOrEnvironment(value, variable string) string {
+	if len(value) > 0 {
+		return value
+	}
+	return os.Getenv(variable)
+}
+
+// webIdentityConfigured indicates whether the environment is configured for web identity (IRSA) credentials.
+func webIdentityConfigured() bool {
+	return len(os.Getenv(envRoleARN)) > 0 && len(os.Getenv(envWebIdentityTokenFile)) > 0
+}
+
+// sharedWebIdentityCredentials supplies a single (cached) provider for each configuration of the role,
+// token file, session name, and STS endpoint in the environment.
+func sharedWebIdentityCredentials() CredentialsProvider {
+	key := strings.Join([]string{SourceWebIdentity,
+		os.Getenv(envRoleARN), os.Getenv(envWebIdentityTokenFile), os.Getenv(envRoleSessionName),
+		stsInput(nil).stsEndpoint(),
+	}, "\n")
+	return sharedCredentials(key, func() CredentialsProvider {
+		return NewWebIdentityCredentialsProvider("", "", "")
+	})
+}
+
+var errWebIdentityNotConfigured = errors.New("web identity role and token file not configured")
+
+const (
+	envRoleARN              = "AWS_ROLE_ARN"
+	envWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	envRoleSessionName      = "AWS_ROLE_SESSION_NAME"
+
+	stsAssumeRoleWithWebIdentity = "AssumeRoleWithWebIdentity"
+)
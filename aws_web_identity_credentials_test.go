@@ -0,0 +1,220 @@
+package s3
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestWebIdentityCredentialsFixture(t *testing.T) {
+	gunit.Run(new(WebIdentityCredentialsFixture), t, gunit.Options.AllSequential())
+}
+
+type WebIdentityCredentialsFixture struct {
+	*gunit.Fixture
+	environment *TemporaryEnvironment
+	server      *httptest.Server
+	folder      string
+	tokenFile   string
+	form        url.Values
+	requests    int
+	status      int
+	response    string
+}
+
+func (this *WebIdentityCredentialsFixture) Setup() {
+	this.folder, _ = ioutil.TempDir("", "s3-web-identity")
+	this.tokenFile = filepath.Join(this.folder, "token")
+	_ = ioutil.WriteFile(this.tokenFile, []byte("oidc-token\n"), 0600)
+	this.environment = NewTemporaryEnvironment(
+		envAccessKeyID, envAccessKey, envSecretAccessKey, envSecretKey, envSecurityToken,
+		envProfile, envSharedCredentialsFile, envConfigFile,
+		envContainerCredentialsRelativeURI, envContainerCredentialsFullURI,
+		envRoleARN, envWebIdentityTokenFile, envRoleSessionName, envRegion, envDefaultRegion, envSTSEndpoint,
+	)
+	this.environment.Set(envSharedCredentialsFile, filepath.Join(this.folder, "missing"))
+	this.environment.Set(envConfigFile, filepath.Join(this.folder, "missing"))
+
+	this.status = http.StatusOK
+	this.response = webIdentityResponse
+	this.server = httptest.NewServer(http.HandlerFunc(this.serveSTS))
+}
+func (this *WebIdentityCredentialsFixture) Teardown() {
+	this.server.Close()
+	this.environment.Restore()
+	_ = os.RemoveAll(this.folder)
+}
+
+func (this *WebIdentityCredentialsFixture) serveSTS(response http.ResponseWriter, request *http.Request) {
+	this.requests++
+	_ = request.ParseForm()
+	this.form = request.PostForm
+	response.WriteHeader(this.status)
+	_, _ = io.WriteString(response, this.response)
+}
+
+func (this *WebIdentityCredentialsFixture) TestAssumeRoleWithWebIdentity() {
+	provider := NewWebIdentityCredentialsProvider("arn:aws:iam::123456789012:role/pod", this.tokenFile, "session", Endpoint(this.server.URL))
+
+	value, err := provider.Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(this.form.Get("Action"), should.Equal, "AssumeRoleWithWebIdentity")
+	this.So(this.form.Get("Version"), should.Equal, "2011-06-15")
+	this.So(this.form.Get("RoleArn"), should.Equal, "arn:aws:iam::123456789012:role/pod")
+	this.So(this.form.Get("RoleSessionName"), should.Equal, "session")
+	this.So(this.form.Get("WebIdentityToken"), should.Equal, "oidc-token")
+	this.So(value, should.Resemble, CredentialsValue{
+		AccessKeyID:     "ASIAWEBIDENTITY",
+		SecretAccessKey: "web-identity-secret",
+		SessionToken:    "web-identity-token",
+		Expiration:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:          SourceWebIdentity,
+	})
+}
+
+func (this *WebIdentityCredentialsFixture) TestCredentialsAreCachedUntilExpiration() {
+	provider := NewWebIdentityCredentialsProvider("role", this.tokenFile, "session", Endpoint(this.server.URL))
+
+	_, _ = provider.Retrieve()
+	_, _ = provider.Retrieve()
+	this.So(this.requests, should.Equal, 1)
+}
+
+func (this *WebIdentityCredentialsFixture) TestExpiringCredentialsAreRefreshed() {
+	this.response = strings.Replace(webIdentityResponse, "2030-01-02T03:04:05Z", time.Now().Add(time.Minute).UTC().Format(time.RFC3339), 1)
+	provider := NewWebIdentityCredentialsProvider("role", this.tokenFile, "session", Endpoint(this.server.URL))
+
+	_, _ = provider.Retrieve()
+	_, _ = provider.Retrieve()
+	this.So(this.requests, should.Equal, 2)
+}
+
+func (this *WebIdentityCredentialsFixture) TestConfigurationFromEnvironment() {
+	this.environment.Set(envRoleARN, "environment-role")
+	this.environment.Set(envWebIdentityTokenFile, this.tokenFile)
+	this.environment.Set(envRoleSessionName, "environment-session")
+	this.environment.Set(envSTSEndpoint, this.server.URL)
+
+	_, err := NewWebIdentityCredentialsProvider("", "", "").Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(this.form.Get("RoleArn"), should.Equal, "environment-role")
+	this.So(this.form.Get("RoleSessionName"), should.Equal, "environment-session")
+}
+
+func (this *WebIdentityCredentialsFixture) TestDefaultSessionName() {
+	_, err := NewWebIdentityCredentialsProvider("role", this.tokenFile, "", Endpoint(this.server.URL)).Retrieve()
+
+	this.So(err, should.BeNil)
+	this.So(this.form.Get("RoleSessionName"), should.StartWith, "s3-")
+}
+
+func (this *WebIdentityCredentialsFixture) TestNotConfigured() {
+	_, err := NewWebIdentityCredentialsProvider("", "", "").Retrieve()
+
+	this.So(err, should.Equal, errWebIdentityNotConfigured)
+	this.So(this.requests, should.Equal, 0)
+}
+
+func (this *WebIdentityCredentialsFixture) TestMissingTokenFile() {
+	_, err := NewWebIdentityCredentialsProvider("role", filepath.Join(this.folder, "missing"), "", Endpoint(this.server.URL)).Retrieve()
+
+	this.So(err, should.NotBeNil)
+	this.So(this.requests, should.Equal, 0)
+}
+
+func (this *WebIdentityCredentialsFixture) TestSTSFailure() {
+	this.status = http.StatusForbidden
+	this.response = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>AccessDenied</Code>
+    <Message>Not authorized to perform sts:AssumeRoleWithWebIdentity</Message>
+  </Error>
+  <RequestId>request-id</RequestId>
+</ErrorResponse>`
+
+	_, err := NewWebIdentityCredentialsProvider("role", this.tokenFile, "", Endpoint(this.server.URL)).Retrieve()
+
+	this.So(err, should.NotBeNil)
+	this.So(err.Error(), should.ContainSubstring, "403 AccessDenied: Not authorized")
+}
+
+func (this *WebIdentityCredentialsFixture) TestRegionalEndpoint() {
+	in := stsInput([]Option{Region("eu-west-1")})
+	this.So(in.stsEndpoint(), should.Equal, "https://sts.eu-west-1.amazonaws.com")
+
+	in = stsInput(nil)
+	this.So(in.stsEndpoint(), should.Equal, "https://sts.amazonaws.com")
+
+	this.environment.Set(envRegion, "us-west-2")
+	in = stsInput(nil)
+	this.So(in.stsEndpoint(), should.Equal, "https://sts.us-west-2.amazonaws.com")
+}
+
+func (this *WebIdentityCredentialsFixture) TestWebIdentityCredentialsOption() {
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"),
+		WebIdentityCredentials("role", this.tokenFile, "session", Endpoint(this.server.URL)))
+
+	this.So(err, should.BeNil)
+	this.So(request.URL.Host, should.Equal, "s3.amazonaws.com")
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=ASIAWEBIDENTITY/")
+	this.So(request.Header.Get("X-Amz-Security-Token"), should.Equal, "web-identity-token")
+}
+
+func (this *WebIdentityCredentialsFixture) TestAmbientCredentialsFromWebIdentity() {
+	this.environment.Set(envRoleARN, "environment-role")
+	this.environment.Set(envWebIdentityTokenFile, this.tokenFile)
+	this.environment.Set(envSTSEndpoint, this.server.URL)
+
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "Credential=ASIAWEBIDENTITY/")
+}
+
+func (this *WebIdentityCredentialsFixture) TestAmbientCredentialsFollowReconfiguredRole() {
+	this.environment.Set(envRoleARN, "environment-role")
+	this.environment.Set(envWebIdentityTokenFile, this.tokenFile)
+	this.environment.Set(envSTSEndpoint, this.server.URL)
+	_, _ = NewRequest(GET, Bucket("bucket"), Key("key"))
+	this.environment.Set(envRoleARN, "other-role")
+
+	_, err := NewRequest(GET, Bucket("bucket"), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(this.requests, should.Equal, 2)
+	this.So(this.form.Get("RoleArn"), should.Equal, "other-role")
+}
+
+const webIdentityResponse = `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <SubjectFromWebIdentityToken>system:serviceaccount:default:pod</SubjectFromWebIdentityToken>
+    <Audience>sts.amazonaws.com</Audience>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/pod/session</Arn>
+      <AssumedRoleId>AROACLKWSDQRAOEXAMPLE:session</AssumedRoleId>
+    </AssumedRoleUser>
+    <Credentials>
+      <SessionToken>web-identity-token</SessionToken>
+      <SecretAccessKey>web-identity-secret</SecretAccessKey>
+      <Expiration>2030-01-02T03:04:05Z</Expiration>
+      <AccessKeyId>ASIAWEBIDENTITY</AccessKeyId>
+    </Credentials>
+    <Provider>oidc.eks.amazonaws.com</Provider>
+  </AssumeRoleWithWebIdentityResult>
+  <ResponseMetadata>
+    <RequestId>request-id</RequestId>
+  </ResponseMetadata>
+</AssumeRoleWithWebIdentityResponse>`
@@ -1,22 +1,29 @@
 package s3
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type inputModel struct {
-	credentials []awsCredentials
+	credentials      []awsCredentials
+	credentialErrors []error
 
-	method   string
-	endpoint string
-	region   string
-	bucket   string
-	key      string
+	method        string
+	endpoint      string
+	region        string
+	profileRegion string
+	service       string
+	bucket        string
+	key           string
+	keyPrefix     string
+	query         url.Values
 
 	now        time.Time
 	expireTime time.Time
@@ -28,23 +35,67 @@ type inputModel struct {
 	contentEncoding string
 	contentMD5      string
 	contentLength   int64
+	chunkSize       int
+	unsignedPayload bool
+
+	signedHeaders  []string
+	querySignature bool
+	signingTrace   func(SigningDetails)
+	signatureV4A   bool
+	signatureV2    bool
+	regionSet      []string
+
+	contentTypePrefix  string
+	contentLengthRange bool
+	contentLengthMin   int64
+	contentLengthMax   int64
 
 	serverSideEncryption ServerSideEncryptionValue
+	addressing           addressingStyle
+
+	partSize     int64
+	concurrency  int
+	partAttempts int
+
+	externalID   string
+	roleDuration time.Duration
+	mfaSerial    string
+	mfaTokenCode func() (string, error)
 }
 
 func newInput(method string, options []Option) *inputModel {
 	return new(inputModel).applyOptions(append(options, method_(method)))
 }
 
+// newBareInput applies the options without resolving (ambient) credentials or a region.
+func newBareInput(options []Option) *inputModel {
+	in := new(inputModel)
+	for _, option := range options {
+		if option != nil {
+			option(in)
+		}
+	}
+	if in.now.IsZero() {
+		in.now = time.Now().UTC()
+	}
+	return in
+}
+
 func (this *inputModel) applyOptions(options []Option) *inputModel {
 	for _, option := range options {
 		if option != nil {
 			option(this)
 		}
 	}
-	if len(this.credentials) == 0 {
+	if len(this.credentials) == 0 && len(this.credentialErrors) == 0 {
 		AmbientCredentials()(this)
 	}
+	if len(this.region) == 0 {
+		EnvironmentRegion()(this)
+	}
+	if len(this.region) == 0 {
+		this.region = this.profileRegion // as with the AWS SDKs, the environment takes precedence over the config file
+	}
 	if len(this.region) == 0 {
 		Region("us-east-1")(this)
 	}
@@ -56,43 +107,178 @@ func (this *inputModel) applyOptions(options []Option) *inputModel {
 }
 
 func (this *inputModel) validate() error {
-	if this.method != HEAD && this.method != GET && this.method != PUT {
+	switch this.method {
+	case HEAD, GET, PUT, DELETE, POST:
+	default:
 		return ErrInvalidRequestMethod
 	}
 	if len(this.bucket) == 0 {
 		return ErrBucketMissing
 	}
-	if len(this.key) == 0 {
+	if err := this.validateEndpoint(); err != nil {
+		return err
+	}
+	if len(this.key) == 0 && !this.bucketOperation() {
 		return ErrKeyMissing
 	}
 	if this.method == PUT && this.content == nil {
 		return ErrContentMissing
 	}
+	if this.signatureV2 && this.streaming() {
+		return ErrStreamingSignatureV2
+	}
+	if this.signatureV4A && this.streaming() {
+		return ErrStreamingSignatureV4A
+	}
+	if this.multiRegionAccessPoint() && this.addressing == pathStyleAddressing {
+		return ErrPathStyleMultiRegionAccessPoint
+	}
+	return this.validateCredentials()
+}
+
+func (this *inputModel) validatePresigned() error {
+	switch this.method {
+	case HEAD, GET, PUT, DELETE:
+	default:
+		return ErrInvalidRequestMethod
+	}
+	if len(this.bucket) == 0 {
+		return ErrBucketMissing
+	}
+	if err := this.validateEndpoint(); err != nil {
+		return err
+	}
+	if len(this.key) == 0 {
+		return ErrKeyMissing
+	}
+	if this.multiRegionAccessPoint() && this.addressing == pathStyleAddressing {
+		return ErrPathStyleMultiRegionAccessPoint
+	}
+	return this.validateCredentials()
+}
+
+func (this *inputModel) validatePresignedPost() error {
+	if len(this.bucket) == 0 {
+		return ErrBucketMissing
+	}
+	if err := this.validateEndpoint(); err != nil {
+		return err
+	}
+	if len(this.key) == 0 && len(this.keyPrefix) == 0 {
+		return ErrKeyMissing
+	}
+	if this.expireTime.IsZero() && this.expiresIn <= 0 {
+		return ErrExpirationMissing
+	}
+	return this.validateCredentials()
+}
+
+// validateEndpoint rejects an alternate endpoint that isn't an absolute http(s) URL (such as "localhost:9000",
+// which parses with "localhost" as the scheme) rather than sending the request to AWS instead.
+func (this *inputModel) validateEndpoint() error {
+	if len(this.endpoint) == 0 {
+		return nil
+	}
+	endpoint, err := url.Parse(this.endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || len(endpoint.Host) == 0 {
+		return fmt.Errorf("%w: %q", ErrInvalidEndpoint, this.endpoint)
+	}
 	return nil
 }
 
+// validateCredentials reports why no usable credentials were resolved (including why any providers failed).
+func (this *inputModel) validateCredentials() error {
+	credentials := this.credential()
+	if !credentials.complete() {
+		if len(this.credentialErrors) == 0 {
+			return ErrCredentialsMissing
+		}
+		return fmt.Errorf("%w: %w", ErrCredentialsMissing, errors.Join(this.credentialErrors...))
+	}
+	if !credentials.Expiration.IsZero() && !this.now.Before(credentials.Expiration) {
+		return fmt.Errorf("%w: %s credentials expired at %s", ErrCredentialsExpired,
+			credentials.Source, credentials.Expiration.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// bucketOperation indicates whether the request targets the bucket itself
+// rather than an object (ie. a multi-object delete) and so needs no key.
+func (this *inputModel) bucketOperation() bool {
+	_, found := this.query["delete"]
+	return this.method == POST && found
+}
+
 func (this *inputModel) buildAndSignRequest() (request *http.Request, err error) {
 	request, err = http.NewRequest(this.method, this.buildURL(), this.content)
 	if err != nil {
 		return nil, err
 	}
 
-	this.prepareRequestForSigning(request)
-	signature := calculateAWSv4Signature(this.region, request, this.credential())
-	request.Header.Set("Authorization", signature)
+	if err = this.prepareRequestForSigning(request); err != nil {
+		return nil, err
+	}
+	if this.signatureV2 {
+		this.signRequestV2(request)
+		return request, nil
+	}
+	signature, err := this.signRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", signature.task4_AuthorizationHeader)
+	if this.streaming() {
+		this.streamSignedChunks(request, signature.task3_IntermediateSignature)
+	}
 	return request, nil
 }
 
-func (this *inputModel) prepareRequestForSigning(request *http.Request) {
+func (this *inputModel) signRequest(request *http.Request) (v4Signature, error) {
+	bodyDigest := request.Header.Get("X-Amz-Content-Sha256")
+	if this.signatureV4A {
+		signer := newV4aSigner(awsS3Service, bodyDigest, request, this.credential())
+		signature, err := signer.calculateSignature()
+		if err != nil {
+			return v4Signature{}, err
+		}
+		this.trace(signer.signingDetails(signature))
+		return signature, nil
+	}
+	signer := newV4Signer(awsS3Service, this.region, bodyDigest, request, this.credential())
+	signature := signer.calculateSignature()
+	this.trace(signer.signingDetails(signature))
+	return signature, nil
+}
+
+func (this *inputModel) signRequestV2(request *http.Request) {
+	credentials := this.credential()
+	stringToSign := stringToSignV2(this.method, request.Header, request.Header.Get("Date"),
+		canonicalResourceV2(this.bucket, this.key, this.query))
+	signature := signV2(credentials.SecretAccessKey, stringToSign)
+	this.trace(SigningDetails{StringToSign: stringToSign, Signature: signature})
+	request.Header.Set("Authorization", awsV2SignatureAlgorithm+" "+credentials.AccessKeyID+":"+signature)
+}
+
+func (this *inputModel) trace(details SigningDetails) {
+	if this.signingTrace != nil {
+		this.signingTrace(details)
+	}
+}
+
+func (this *inputModel) prepareRequestForSigning(request *http.Request) error {
 	if request.URL.Path == "" {
 		request.URL.Path += "/"
 	}
-	if this.contentLength > 0 {
-		request.ContentLength = this.contentLength
-	}
 	if len(this.contentType) == 0 {
 		this.contentType = "application/x-www-form-urlencoded; charset=utf-8"
 	}
+	payloadDigest, err := this.preparePayload(request)
+	if err != nil {
+		return err
+	}
+	if this.contentLength > 0 {
+		request.ContentLength = this.contentLength
+	}
 	setHeader(request, "Host", request.Host) // This must be included in range of headers to sign
 	setHeader(request, "Content-Length", formatInt64(this.contentLength))
 	setHeader(request, "Content-Encoding", this.contentEncoding)
@@ -101,16 +287,79 @@ func (this *inputModel) prepareRequestForSigning(request *http.Request) {
 	setHeader(request, "If-None-Match", this.etag)
 	setHeader(request, "X-Amz-Server-Side-Encryption", string(this.serverSideEncryption))
 	setHeader(request, "X-Amz-Security-Token", this.credential().SecurityToken)
-	setHeader(request, "X-Amz-Content-Sha256", hashSHA256(readAndReplaceBody(request)))
 	setHeader(request, "X-Amz-Expires", formatUnixTimeStamp(this.expireTime))
+	if this.signatureV2 {
+		setHeader(request, "Date", this.now.UTC().Format(http.TimeFormat))
+		return nil
+	}
+	setHeader(request, "X-Amz-Content-Sha256", payloadDigest)
 	setHeader(request, "X-Amz-Date", this.timestampV4())
+	if this.signatureV4A {
+		setHeader(request, headerRegionSet, regionSetV4A(this.regionSet))
+	}
+	return nil
+}
+
+func (this *inputModel) preparePayload(request *http.Request) (digest string, err error) {
+	if this.streaming() {
+		return awsV4StreamingPayload, this.prepareStreamingPayload(request)
+	}
+	if this.unsignedPayload && !this.needsContentMD5() {
+		return awsV4UnsignedPayload, nil
+	}
+	payload := readAndReplaceBody(request)
+	if this.needsContentMD5() && len(payload) > 0 {
+		this.contentMD5 = hashMD5(payload) // required by operations like multi-object delete
+	}
+	if this.unsignedPayload {
+		return awsV4UnsignedPayload, nil
+	}
+	return hashSHA256(payload), nil
+}
+
+// needsContentMD5 indicates whether the checksum of the payload must be calculated, even if it isn't signed.
+func (this *inputModel) needsContentMD5() bool {
+	return this.method == POST && len(this.contentMD5) == 0
+}
+
+func (this *inputModel) streaming() bool {
+	return this.chunkSize > 0 && this.method == PUT
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+func (this *inputModel) prepareStreamingPayload(request *http.Request) error {
+	decodedLength := this.contentLength
+	if decodedLength == 0 {
+		length, err := remainingLength(this.content)
+		if err != nil {
+			return err
+		}
+		decodedLength = length
+	}
+	if len(this.contentEncoding) == 0 {
+		this.contentEncoding = awsChunkedContentEncoding
+	} else {
+		this.contentEncoding = awsChunkedContentEncoding + "," + this.contentEncoding
+	}
+	this.contentLength = chunkedContentLength(decodedLength, this.chunkSize)
+	request.Header.Set("X-Amz-Decoded-Content-Length", formatInt64(decodedLength))
+	return nil
+}
+
+func (this *inputModel) streamSignedChunks(request *http.Request, seedSignature string) {
+	signingKey := deriveSigningKeyV4(this.credential().SecretAccessKey, timestampDateV4(this.timestampV4()), this.region, "s3")
+	reader := newV4ChunkedReader(this.content, this.chunkSize, signingKey, this.timestampV4(), this.credentialScope(), seedSignature)
+	request.Body = io.NopCloser(reader)
+	request.GetBody = nil
 }
 
-func (this *inputModel) buildVirtualHostname() string {
+func (this *inputModel) buildAmazonHostname() string {
+	if this.multiRegionAccessPoint() {
+		return multiRegionAccessPointHostname
+	}
 	builder := new(strings.Builder)
-	builder.WriteString(this.bucket)
-	builder.WriteString(".s3")
-	if this.region != "us-east-1" {
+	builder.WriteString("s3")
+	if len(this.region) > 0 && this.region != "us-east-1" {
 		builder.WriteString("-")
 		builder.WriteString(this.region)
 	}
@@ -118,50 +367,61 @@ func (this *inputModel) buildVirtualHostname() string {
 	return builder.String()
 }
 
-// https://docs.aws.amazon.com/AmazonS3/latest/dev/VirtualHosting.html
-func (this *inputModel) buildVirtualHostingURL() string {
-	builder := new(strings.Builder)
-	if len(this.endpoint) > 0 {
-		builder.WriteString(this.endpoint)
-	} else {
-		builder.WriteString("https://")
-		builder.WriteString(this.buildVirtualHostname())
-		builder.WriteString("/")
-	}
-
-	if len(this.endpoint) > 0 {
-		if !strings.HasSuffix(builder.String(), "/") {
-			builder.WriteString("/")
-		}
-		builder.WriteString(this.bucket)
-		builder.WriteString("/")
+// usePathStyle indicates whether the bucket appears in the path of the URL (rather than the hostname),
+// falling back to the provided value when no addressing style was specified.
+func (this *inputModel) usePathStyle(fallback bool) bool {
+	switch this.addressing {
+	case pathStyleAddressing:
+		return true
+	case virtualHostedStyleAddressing:
+		return false
+	default:
+		return fallback && !this.multiRegionAccessPoint()
 	}
+}
 
-	builder.WriteString(this.key)
-	return builder.String()
+// multiRegionAccessPoint indicates whether the bucket is the alias of a Multi-Region Access Point
+// (such as "mfzwi23gnjvgw.mrap"), which is only addressed (virtual-hosted style) by way of SigV4A.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/MultiRegionAccessPointRequests.html
+func (this *inputModel) multiRegionAccessPoint() bool {
+	return this.signatureV4A && !this.signatureV2 && strings.HasSuffix(this.bucket, multiRegionAccessPointSuffix)
 }
 
-func (this *inputModel) buildURL() string {
-	builder := new(strings.Builder)
+// buildAddress composes the URL of the object, including the host
+// (and port) of any alternate endpoint, along with any query parameters.
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/VirtualHosting.html
+func (this *inputModel) buildAddress(pathStyle bool) *url.URL {
+	address := this.buildBucketAddress(pathStyle)
+	address.Path += this.key
+	address.RawPath = normalizeURI(address.Path)
+
+	if len(this.query) > 0 {
+		address.RawQuery = normalizeQuery(this.query)
+	}
+	return address
+}
 
+// buildBucketAddress composes the URL of the bucket (the path of which always ends with a slash).
+func (this *inputModel) buildBucketAddress(pathStyle bool) *url.URL {
+	address := &url.URL{Scheme: "https", Host: this.buildAmazonHostname()}
 	if len(this.endpoint) > 0 {
-		builder.WriteString(this.endpoint)
-	} else {
-		builder.WriteString("https://s3")
-		if len(this.region) > 0 && this.region != "us-east-1" {
-			builder.WriteString("-")
-			builder.WriteString(this.region)
-		}
-		builder.WriteString(".amazonaws.com")
+		endpoint, _ := url.Parse(this.endpoint) // see validateEndpoint
+		address.Scheme = endpoint.Scheme
+		address.Host = endpoint.Host
+		address.Path = strings.TrimSuffix(endpoint.Path, "/")
 	}
 
-	if !strings.HasSuffix(builder.String(), "/") {
-		builder.WriteString("/")
+	if pathStyle {
+		address.Path += "/" + this.bucket
+	} else {
+		address.Host = this.bucket + "." + address.Host
 	}
-	builder.WriteString(this.bucket)
-	builder.WriteString("/")
-	builder.WriteString(this.key)
-	return builder.String()
+	address.Path += "/"
+	return address
+}
+
+func (this *inputModel) buildURL() string {
+	return this.buildAddress(this.usePathStyle(true)).String()
 }
 
 func (this *inputModel) timestampV4() string {
@@ -179,17 +439,41 @@ func (this *inputModel) fullCredentialScope() string {
 	)
 }
 
+// credential chooses the first of the credentials (in the order the options were applied) that is complete
+// and not (about to be) expired. Failing that, it chooses the complete credential that expires last, which
+// may yet be valid for a few minutes (or else validation will report its expiration).
 func (this *inputModel) credential() (credentials awsCredentials) {
-	if len(this.credentials) > 0 {
-		return this.credentials[0]
-	} else {
-		return credentials
+	latest := -1
+	for i, candidate := range this.credentials {
+		if !candidate.complete() {
+			continue
+		}
+		if !candidate.expired() {
+			return candidate
+		}
+		if latest < 0 || candidate.Expiration.After(this.credentials[latest].Expiration) {
+			latest = i
+		}
+	}
+	if latest >= 0 {
+		return this.credentials[latest]
 	}
+	return credentials
+}
+
+func (this *inputModel) hasUsableCredential() bool {
+	credentials := this.credential()
+	return credentials.complete() && !credentials.expired()
 }
 
 func (this *inputModel) credentialScope() string {
 	return fmt.Sprintf("%s/%s/%s/%s",
 		timestampDateV4(this.timestampV4()), this.region,
-		"s3", awsV4CredentialScopeTerminationString,
+		awsS3Service, awsV4CredentialScopeTerminationString,
 	) // YYYYMMDD/us-east-1/s3/aws4_request
 }
+
+const (
+	multiRegionAccessPointSuffix   = ".mrap"
+	multiRegionAccessPointHostname = "accesspoint.s3-global.amazonaws.com"
+)
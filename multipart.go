@@ -0,0 +1,141 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// NewCreateMultipartUploadRequest builds a signed request that initiates a multipart upload.
+// The UploadId needed by subsequent requests is found in the response, see ParseCreateMultipartUploadResponse.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_CreateMultipartUpload.html
+func NewCreateMultipartUploadRequest(options ...Option) (*http.Request, error) {
+	return NewRequest(POST, CompositeOption(options...), SubResource("uploads"))
+}
+
+// NewUploadPartRequest builds a signed request that uploads a single part (numbered 1 to 10,000)
+// of a multipart upload. The part's payload is specified with any of the Content options.
+// The resulting ETag is found in the response, see ParseUploadPartResponse.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_UploadPart.html
+func NewUploadPartRequest(uploadID string, partNumber int, options ...Option) (*http.Request, error) {
+	if len(uploadID) == 0 {
+		return nil, ErrUploadIDMissing
+	}
+	if partNumber < minimumPartNumber || partNumber > maximumPartNumber {
+		return nil, ErrInvalidPartNumber
+	}
+	return NewRequest(PUT, CompositeOption(options...), UploadID(uploadID), PartNumber(partNumber))
+}
+
+// NewCompleteMultipartUploadRequest builds a signed request that assembles the previously uploaded parts.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_CompleteMultipartUpload.html
+func NewCompleteMultipartUploadRequest(uploadID string, parts []CompletedPart, options ...Option) (*http.Request, error) {
+	if len(uploadID) == 0 {
+		return nil, ErrUploadIDMissing
+	}
+	if len(parts) == 0 {
+		return nil, ErrPartsMissing
+	}
+	manifest, err := xml.Marshal(newCompleteMultipartUpload(parts))
+	if err != nil {
+		return nil, err
+	}
+	return NewRequest(POST, ContentType("application/xml"), CompositeOption(options...),
+		UploadID(uploadID), ContentBytes(manifest))
+}
+
+// NewAbortMultipartUploadRequest builds a signed request that discards all parts of the multipart upload.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_AbortMultipartUpload.html
+func NewAbortMultipartUploadRequest(uploadID string, options ...Option) (*http.Request, error) {
+	if len(uploadID) == 0 {
+		return nil, ErrUploadIDMissing
+	}
+	return NewRequest(DELETE, CompositeOption(options...), UploadID(uploadID))
+}
+
+// CreateMultipartUploadResult is the response body of a CreateMultipartUpload request.
+type CreateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// CompletedPart identifies an uploaded part in the CompleteMultipartUpload manifest.
+type CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// CompleteMultipartUploadResult is the response body of a CompleteMultipartUpload request.
+type CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// ParseCreateMultipartUploadResponse reads the UploadId (and other details) from the response.
+func ParseCreateMultipartUploadResponse(response *http.Response) (result CreateMultipartUploadResult, err error) {
+	err = decodeResponse(response, &result)
+	if err == nil && len(result.UploadID) == 0 {
+		err = ErrUploadIDMissing
+	}
+	return result, err
+}
+
+// ParseUploadPartResponse reads the ETag of the uploaded part from the response.
+func ParseUploadPartResponse(response *http.Response, partNumber int) (part CompletedPart, err error) {
+	if err = decodeResponse(response, nil); err != nil {
+		return part, err
+	}
+	part.PartNumber = partNumber
+	part.ETag = response.Header.Get("ETag")
+	if len(part.ETag) == 0 {
+		return part, ErrETagMissing
+	}
+	return part, nil
+}
+
+// ParseCompleteMultipartUploadResponse reads the details of the assembled object from the response.
+// Note that S3 may report a failure with a 200 OK status, in which case the *ResponseError is returned.
+func ParseCompleteMultipartUploadResponse(response *http.Response) (result CompleteMultipartUploadResult, err error) {
+	err = decodeResponse(response, &result)
+	return result, err
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPart `xml:"Part"`
+}
+
+func newCompleteMultipartUpload(parts []CompletedPart) completeMultipartUpload {
+	sorted := append([]CompletedPart{}, parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+	for i := range sorted {
+		sorted[i].ETag = quoteETag(sorted[i].ETag)
+	}
+	return completeMultipartUpload{Parts: sorted}
+}
+
+func quoteETag(value string) string {
+	if strings.HasPrefix(value, `"`) {
+		return value
+	}
+	return `"` + value + `"`
+}
+
+const (
+	minimumPartNumber = 1
+	maximumPartNumber = 10000
+)
+
+var (
+	ErrUploadIDMissing   = errors.New("upload id is required")
+	ErrInvalidPartNumber = errors.New("part number must be between 1 and 10000")
+	ErrPartsMissing      = errors.New("at least one completed part is required")
+	ErrETagMissing       = errors.New("etag is missing from response")
+)
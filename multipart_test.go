@@ -0,0 +1,154 @@
+package s3
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestMultipartFixture(t *testing.T) {
+	gunit.Run(new(MultipartFixture), t)
+}
+
+type MultipartFixture struct {
+	*gunit.Fixture
+	options Option
+}
+
+func (this *MultipartFixture) Setup() {
+	this.options = CompositeOption(Credentials("access", "secret"), Region("us-east-1"), Bucket("bucket"), Key("key"))
+}
+
+func (this *MultipartFixture) TestCreateMultipartUploadRequest() {
+	request, err := NewCreateMultipartUploadRequest(this.options)
+	this.So(err, should.BeNil)
+	this.So(request.Method, should.Equal, POST)
+	this.So(request.URL.String(), should.Equal, "https://s3.amazonaws.com/bucket/key?uploads=")
+}
+
+func (this *MultipartFixture) TestUploadPartRequest() {
+	request, err := NewUploadPartRequest("upload-id", 3, this.options, ContentString("part"))
+	this.So(err, should.BeNil)
+	this.So(request.Method, should.Equal, PUT)
+	this.So(request.URL.Query().Get("uploadId"), should.Equal, "upload-id")
+	this.So(request.URL.Query().Get("partNumber"), should.Equal, "3")
+	this.So(request.Header.Get("X-Amz-Content-Sha256"), should.Equal, hashSHA256([]byte("part")))
+}
+
+func (this *MultipartFixture) TestUploadPartRequest_Invalid() {
+	_, err := NewUploadPartRequest("", 1, this.options, ContentString("part"))
+	this.So(err, should.Equal, ErrUploadIDMissing)
+	_, err = NewUploadPartRequest("upload-id", 0, this.options, ContentString("part"))
+	this.So(err, should.Equal, ErrInvalidPartNumber)
+	_, err = NewUploadPartRequest("upload-id", 10001, this.options, ContentString("part"))
+	this.So(err, should.Equal, ErrInvalidPartNumber)
+}
+
+func (this *MultipartFixture) TestCompleteMultipartUploadRequest() {
+	request, err := NewCompleteMultipartUploadRequest("upload-id", []CompletedPart{
+		{PartNumber: 2, ETag: "etag-2"},
+		{PartNumber: 1, ETag: `"etag-1"`},
+	}, this.options)
+
+	this.So(err, should.BeNil)
+	this.So(request.Method, should.Equal, POST)
+	this.So(request.URL.Query().Get("uploadId"), should.Equal, "upload-id")
+	this.So(request.Header.Get("Content-Type"), should.Equal, "application/xml")
+	this.So(request.Header.Get("Content-MD5"), should.NotBeBlank)
+	body, _ := ioutil.ReadAll(request.Body)
+	this.So(string(body), should.Equal, "<CompleteMultipartUpload>"+
+		"<Part><PartNumber>1</PartNumber><ETag>&#34;etag-1&#34;</ETag></Part>"+
+		"<Part><PartNumber>2</PartNumber><ETag>&#34;etag-2&#34;</ETag></Part>"+
+		"</CompleteMultipartUpload>")
+}
+
+func (this *MultipartFixture) TestCompleteMultipartUploadRequest_Invalid() {
+	_, err := NewCompleteMultipartUploadRequest("upload-id", nil, this.options)
+	this.So(err, should.Equal, ErrPartsMissing)
+	_, err = NewCompleteMultipartUploadRequest("", []CompletedPart{{PartNumber: 1, ETag: "a"}}, this.options)
+	this.So(err, should.Equal, ErrUploadIDMissing)
+}
+
+func (this *MultipartFixture) TestAbortMultipartUploadRequest() {
+	request, err := NewAbortMultipartUploadRequest("upload-id", this.options)
+	this.So(err, should.BeNil)
+	this.So(request.Method, should.Equal, DELETE)
+	this.So(request.URL.String(), should.Equal, "https://s3.amazonaws.com/bucket/key?uploadId=upload-id")
+}
+
+func (this *MultipartFixture) TestParseCreateMultipartUploadResponse() {
+	result, err := ParseCreateMultipartUploadResponse(newResponse(http.StatusOK, `<?xml version="1.0" encoding="UTF-8"?>
+<InitiateMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Bucket>bucket</Bucket>
+  <Key>key</Key>
+  <UploadId>VXBsb2FkIElEIGZvciA2aWWpbmcncyBteS1tb3ZpZS5tMnRzIHVwbG9hZA</UploadId>
+</InitiateMultipartUploadResult>`))
+
+	this.So(err, should.BeNil)
+	this.So(result.Bucket, should.Equal, "bucket")
+	this.So(result.Key, should.Equal, "key")
+	this.So(result.UploadID, should.Equal, "VXBsb2FkIElEIGZvciA2aWWpbmcncyBteS1tb3ZpZS5tMnRzIHVwbG9hZA")
+}
+
+func (this *MultipartFixture) TestParseUploadPartResponse() {
+	response := newResponse(http.StatusOK, "")
+	response.Header.Set("ETag", `"b54357faf0632cce46e942fa68356b38"`)
+
+	part, err := ParseUploadPartResponse(response, 7)
+
+	this.So(err, should.BeNil)
+	this.So(part, should.Resemble, CompletedPart{PartNumber: 7, ETag: `"b54357faf0632cce46e942fa68356b38"`})
+}
+
+func (this *MultipartFixture) TestParseUploadPartResponse_MissingETag() {
+	_, err := ParseUploadPartResponse(newResponse(http.StatusOK, ""), 7)
+	this.So(err, should.Equal, ErrETagMissing)
+}
+
+func (this *MultipartFixture) TestParseCompleteMultipartUploadResponse() {
+	result, err := ParseCompleteMultipartUploadResponse(newResponse(http.StatusOK, `<?xml version="1.0" encoding="UTF-8"?>
+<CompleteMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Location>https://bucket.s3.amazonaws.com/key</Location>
+  <Bucket>bucket</Bucket>
+  <Key>key</Key>
+  <ETag>"3858f62230ac3c915f300c664312c11f-9"</ETag>
+</CompleteMultipartUploadResult>`))
+
+	this.So(err, should.BeNil)
+	this.So(result.Location, should.Equal, "https://bucket.s3.amazonaws.com/key")
+	this.So(result.ETag, should.Equal, `"3858f62230ac3c915f300c664312c11f-9"`)
+}
+
+func (this *MultipartFixture) TestParseCompleteMultipartUploadResponse_ErrorWithOKStatus() {
+	_, err := ParseCompleteMultipartUploadResponse(newResponse(http.StatusOK, `<?xml version="1.0" encoding="UTF-8"?>
+<Error>
+  <Code>InternalError</Code>
+  <Message>We encountered an internal error. Please try again.</Message>
+  <RequestId>656c76696e6727732072657175657374</RequestId>
+  <HostId>Uuag1LuByRx9e6j5Onimru9pO4ZVKnJ2Qz7/C1NPcfTWAtRPfTaOFg==</HostId>
+</Error>`))
+
+	failure, ok := err.(*ResponseError)
+	if this.So(ok, should.BeTrue) {
+		this.So(failure.StatusCode, should.Equal, http.StatusOK)
+		this.So(failure.Code, should.Equal, "InternalError")
+		this.So(failure.RequestID, should.Equal, "656c76696e6727732072657175657374")
+	}
+}
+
+func (this *MultipartFixture) TestParseResponse_ErrorStatusWithoutBody() {
+	_, err := ParseCreateMultipartUploadResponse(newResponse(http.StatusForbidden, ""))
+	this.So(err, should.Resemble, &ResponseError{StatusCode: http.StatusForbidden, Code: "Forbidden"})
+}
+
+func newResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
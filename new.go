@@ -6,15 +6,37 @@ import (
 )
 
 func NewPresignedGet(options ...Option) (string, error) {
-	input := newInput(GET, options)
+	return NewPresignedURL(GET, options...)
+}
 
-	if err := input.validate(); err != nil {
+// NewPresignedURL generates a URL that grants temporary access to perform a GET, HEAD,
+// PUT, or DELETE request without further credentials. Any content type, content MD5,
+// or server-side encryption specified in the options becomes a signed header, meaning
+// the request made with the URL must include the same header and value.
+func NewPresignedURL(method string, options ...Option) (string, error) {
+	input := newInput(method, options)
+
+	if err := input.validatePresigned(); err != nil {
 		return "", err
 	}
 
 	return newPresigner(input).GenerateURL()
 }
 
+// NewPresignedPost generates the URL and form fields of an HTML form with which a browser
+// may upload a file directly to S3. The signed policy restricts the upload to the bucket,
+// the key (or KeyPrefix), and any content type, content length range, or server-side
+// encryption specified in the options. An expiration (ExpiresIn or ExpireTime) is required.
+func NewPresignedPost(options ...Option) (PresignedPost, error) {
+	input := newInput(POST, options)
+
+	if err := input.validatePresignedPost(); err != nil {
+		return PresignedPost{}, err
+	}
+
+	return newPostPresigner(input).Generate()
+}
+
 func NewRequest(method string, options ...Option) (*http.Request, error) {
 	input := newInput(method, options)
 
@@ -25,15 +47,39 @@ func NewRequest(method string, options ...Option) (*http.Request, error) {
 	return input.buildAndSignRequest()
 }
 
+// ResolveCredentials reports the credentials that NewRequest (and friends) would sign with given the same
+// options, including the Source that supplied them, or the reason (ErrCredentialsMissing or ErrCredentialsExpired,
+// wrapping any failures of the providers consulted) that no usable credentials could be resolved.
+func ResolveCredentials(options ...Option) (CredentialsValue, error) {
+	input := new(inputModel).applyOptions(options)
+
+	if err := input.validateCredentials(); err != nil {
+		return CredentialsValue{}, err
+	}
+
+	return newCredentialsValue(input.credential()), nil
+}
+
 const (
-	HEAD = "HEAD"
-	GET  = "GET"
-	PUT  = "PUT"
+	HEAD   = "HEAD"
+	GET    = "GET"
+	PUT    = "PUT"
+	DELETE = "DELETE"
+	POST   = "POST"
 )
 
 var (
-	ErrInvalidRequestMethod = errors.New("invalid method")
-	ErrBucketMissing        = errors.New("bucket is required")
-	ErrKeyMissing           = errors.New("key is required")
-	ErrContentMissing       = errors.New("content is required")
+	ErrInvalidRequestMethod  = errors.New("invalid method")
+	ErrBucketMissing         = errors.New("bucket is required")
+	ErrInvalidEndpoint       = errors.New("endpoint must be an absolute http or https URL")
+	ErrKeyMissing            = errors.New("key is required")
+	ErrContentMissing        = errors.New("content is required")
+	ErrExpirationMissing     = errors.New("expiration is required")
+	ErrProfileNotFound       = errors.New("profile not found in the shared credentials or config file")
+	ErrCredentialsMissing    = errors.New("credentials are required")
+	ErrCredentialsExpired    = errors.New("credentials have expired")
+	ErrStreamingSignatureV2  = errors.New("streaming signatures are not supported with SigV2")
+	ErrStreamingSignatureV4A = errors.New("streaming signatures are not supported with SigV4A")
+
+	ErrPathStyleMultiRegionAccessPoint = errors.New("multi-region access points require virtual-hosted style addressing")
 )
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -35,10 +36,27 @@ func CompositeOption(options ...Option) Option {
 }
 
 // Region allows the user to specify the region for sending requests.
+// Given to Verify, it's the only region for which signatures are accepted.
 func Region(value string) Option {
 	return func(in *inputModel) { in.region = value }
 }
 
+// Service specifies the service (such as "sqs" or "execute-api") for which Verify accepts signatures,
+// which is otherwise "s3".
+func Service(value string) Option {
+	return func(in *inputModel) { in.service = value }
+}
+
+// EnvironmentRegion specifies the region named by $AWS_REGION (or else $AWS_DEFAULT_REGION), if any.
+// Requests without a Region default to this region, then to the region of any ProfileCredentials, and then to us-east-1.
+func EnvironmentRegion() Option {
+	return func(in *inputModel) {
+		if region := environmentRegion(); len(region) > 0 {
+			in.region = region
+		}
+	}
+}
+
 // Bucket allows the user to specify the bucket for sending requests.
 func Bucket(value string) Option {
 	return func(in *inputModel) { in.bucket = value }
@@ -55,6 +73,13 @@ func Key(value string) Option {
 	}
 }
 
+// KeyPrefix allows a browser-based upload to choose any key that begins with the prefix.
+// The file's name is appended to the prefix unless the form's key field is changed.
+// This option only applies to NewPresignedPost.
+func KeyPrefix(value string) Option {
+	return func(in *inputModel) { in.keyPrefix = value }
+}
+
 // StorageAddress allows the user to specify the region, bucket, and/or key
 // for sending requests from the provided S3 URL.
 func StorageAddress(value *url.URL) Option {
@@ -71,11 +96,56 @@ func StorageAddress(value *url.URL) Option {
 	)
 }
 
+// VersionID specifies the version of the object to operate on.
+// This option only applies to GET, HEAD, and DELETE requests.
+func VersionID(value string) Option {
+	return QueryParameter("versionId", value)
+}
+
+// SubResource specifies a value-less query parameter that selects an S3 sub-resource,
+// such as "delete" (multi-object delete) or "uploads" (multipart upload initiation).
+func SubResource(name string) Option {
+	return QueryParameter(name, "")
+}
+
+// UploadID specifies the multipart upload to which the request pertains.
+func UploadID(value string) Option {
+	return QueryParameter("uploadId", value)
+}
+
+// PartNumber specifies the part (of a multipart upload) to which the request pertains.
+func PartNumber(value int) Option {
+	return QueryParameter("partNumber", strconv.Itoa(value))
+}
+
+// QueryParameter adds the provided key and value to the query string of the request.
+func QueryParameter(key, value string) Option {
+	return func(in *inputModel) {
+		if in.query == nil {
+			in.query = make(url.Values)
+		}
+		in.query.Set(key, value)
+	}
+}
+
 // Endpoint allows the user to specify an alternate s3-compatible endpoint/URL to use for signed requests.
+// It must be an absolute http or https URL (e.g. "http://localhost:9000"), or else ErrInvalidEndpoint.
 func Endpoint(value string) Option {
 	return func(in *inputModel) { in.endpoint = value }
 }
 
+// PathStyle specifies that the bucket appear in the path of the URL (https://host/bucket/key).
+// This is the default for signed requests and for presigned URLs with an alternate Endpoint.
+func PathStyle() Option {
+	return func(in *inputModel) { in.addressing = pathStyleAddressing }
+}
+
+// VirtualHostedStyle specifies that the bucket appear in the hostname of the URL (https://bucket.host/key).
+// This is the default for presigned URLs without an alternate Endpoint.
+func VirtualHostedStyle() Option {
+	return func(in *inputModel) { in.addressing = virtualHostedStyleAddressing }
+}
+
 // ConditionalOption returns the option if condition == true, otherwise returns nil (nop).
 func ConditionalOption(option Option, condition bool) Option {
 	if condition {
@@ -91,6 +161,7 @@ func Credentials(access, secret string) Option {
 		in.credentials = append(in.credentials, awsCredentials{
 			AccessKeyID:     access,
 			SecretAccessKey: secret,
+			Source:          SourceStatic,
 		})
 	}
 }
@@ -103,32 +174,131 @@ func STSCredentials(access, secret, token string, expiration time.Time) Option {
 			SecretAccessKey: secret,
 			SecurityToken:   token,
 			Expiration:      expiration,
+			Source:          SourceSTS,
 		})
 	}
 }
 
+// CredentialsFrom retrieves credentials from the provider each time the option is applied (that is,
+// for every request). Wrap the provider with NewCachedCredentialsProvider to avoid retrieving
+// credentials more often than necessary. The provider isn't consulted should usable credentials
+// have been specified by an earlier option. Any failure to retrieve credentials is reported by
+// NewRequest (and friends) rather than signing with empty credentials.
+func CredentialsFrom(provider CredentialsProvider) Option {
+	return func(in *inputModel) {
+		if in.hasUsableCredential() {
+			return
+		}
+		value, err := provider.Retrieve()
+		if err != nil {
+			in.credentialErrors = append(in.credentialErrors, err)
+			return
+		}
+		if len(value.Source) == 0 {
+			value.Source = SourceProvider
+		}
+		in.credentials = append(in.credentials, value.awsCredentials())
+	}
+}
+
 // IAMRoleCredentials loads credentials from the EC2 instance's configured IAM role. Only applicable when running on EC2.
+// The credentials are shared by all requests until they are about to expire.
 func IAMRoleCredentials() Option {
+	return CredentialsFrom(iamRoleCredentials)
+}
+
+// ContainerCredentials loads credentials from the container credentials endpoint of ECS tasks and EKS pods
+// (see NewContainerCredentialsProvider). The credentials are shared by all requests (to the same endpoint)
+// until they are about to expire.
+func ContainerCredentials() Option {
+	return func(in *inputModel) { CredentialsFrom(sharedContainerCredentials())(in) }
+}
+
+// WebIdentityCredentials loads credentials from the role assumed with the web identity token in the file,
+// as with IAM roles for service accounts (IRSA) on EKS (see NewWebIdentityCredentialsProvider).
+// The credentials are shared by all requests (with this option) until they are about to expire.
+func WebIdentityCredentials(roleARN, tokenFile, sessionName string, stsOptions ...Option) Option {
+	return CredentialsFrom(NewWebIdentityCredentialsProvider(roleARN, tokenFile, sessionName, stsOptions...))
+}
+
+// AssumeRoleCredentials loads credentials from the role assumed (by way of STS AssumeRole) with the credentials
+// (and any Region, Endpoint, ExternalID, RoleDuration, or MFA options) specified by sourceOptions. Without source
+// credentials, ambient credentials apply. The credentials are shared by all requests (with this option) until they
+// are about to expire. See NewAssumeRoleCredentialsProvider.
+func AssumeRoleCredentials(roleARN, sessionName string, sourceOptions ...Option) Option {
+	return CredentialsFrom(NewAssumeRoleCredentialsProvider(roleARN, sessionName, sourceOptions...))
+}
+
+// ExternalID specifies the external ID required by the trust policy of the role assumed by AssumeRoleCredentials.
+func ExternalID(value string) Option {
+	return func(in *inputModel) { in.externalID = value }
+}
+
+// RoleDuration specifies how long the credentials of the role assumed by AssumeRoleCredentials remain valid.
+func RoleDuration(value time.Duration) Option {
+	return func(in *inputModel) { in.roleDuration = value }
+}
+
+// MFA specifies the serial number (or ARN) of the MFA device required by the trust policy of the role assumed by
+// AssumeRoleCredentials, along with a callback that supplies the current token code whenever the role is assumed.
+func MFA(serialNumber string, tokenCode func() (string, error)) Option {
 	return func(in *inputModel) {
-		in.credentials = append(in.credentials, getIAMRoleCredentials())
+		in.mfaSerial = serialNumber
+		in.mfaTokenCode = tokenCode
 	}
 }
 
-// EnvironmentCredentials loads credentials from common variations of environment variables.
+// ProcessCredentials loads credentials from the output of the command, as with the credential_process
+// setting of the shared config file (see NewProcessCredentialsProvider). The credentials are shared by
+// all requests (with this option) until they are about to expire.
+func ProcessCredentials(command string) Option {
+	return CredentialsFrom(NewProcessCredentialsProvider(command))
+}
+
+// EnvironmentCredentials loads credentials (including $AWS_SESSION_TOKEN and $AWS_CREDENTIAL_EXPIRATION)
+// from common variations of environment variables.
 func EnvironmentCredentials() Option {
 	return func(in *inputModel) {
-		in.credentials = append(in.credentials, loadCredentialsFromEnvironment())
+		credentials, err := loadCredentialsFromEnvironment()
+		if err != nil {
+			in.credentialErrors = append(in.credentialErrors, err)
+			return
+		}
+		in.credentials = append(in.credentials, credentials)
+	}
+}
+
+// ProfileCredentials loads credentials from (or runs the credential_process of) the named profile (or, if blank, the
+// profile named by $AWS_PROFILE, or else the "default" profile) of the shared credentials file (~/.aws/credentials or
+// $AWS_SHARED_CREDENTIALS_FILE) and config file (~/.aws/config or $AWS_CONFIG_FILE). The profile's region applies
+// unless a region is otherwise specified (by the Region option, $AWS_REGION, or $AWS_DEFAULT_REGION).
+func ProfileCredentials(name string) Option {
+	provider := newProfileCredentialsProvider(name)
+	credentials := CredentialsFrom(NewCachedCredentialsProvider(provider))
+	return func(in *inputModel) {
+		credentials(in)
+		if region := provider.Region(); len(region) > 0 {
+			in.profileRegion = region
+		}
 	}
 }
 
-// AmbientCredentials loads credentials first from the environment, then from any configured IAM role (on EC2).
+// AmbientCredentials loads credentials first from the environment, then from the web identity role
+// configured by the environment (for IRSA on EKS), then from the default profile of the shared
+// credentials and config files, then from the container credentials endpoint (on ECS or EKS),
+// then from any configured IAM role (on EC2). Should the credentials come from the profile, its
+// region applies as with ProfileCredentials.
 func AmbientCredentials() Option {
 	return func(in *inputModel) {
-		credentials := ambientCredentials()
-		if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		credentials, region, err := ambientCredentials()
+		if err != nil {
+			in.credentialErrors = append(in.credentialErrors, err)
 			return
 		}
 		in.credentials = append(in.credentials, credentials)
+		if len(region) > 0 {
+			in.profileRegion = region
+		}
 	}
 }
 
@@ -144,12 +314,12 @@ func ExpireTime(value time.Time) Option {
 	return func(in *inputModel) { in.expireTime = value }
 }
 
-// ExpiresIn specifies a validity period for PresignedGet (only).
+// ExpiresIn specifies a validity period for presigned URLs and POST policies.
 func ExpiresIn(value time.Duration) Option {
 	return func(in *inputModel) { in.expiresIn = value }
 }
 
-// ContentString specifies the PUT request payload from a string.
+// ContentString specifies the PUT (or POST) request payload from a string.
 func ContentString(value string) Option {
 	return func(in *inputModel) {
 		in.content = strings.NewReader(value)
@@ -157,7 +327,7 @@ func ContentString(value string) Option {
 	}
 }
 
-// ContentBytes specifies the PUT request payload from a slice of bytes.
+// ContentBytes specifies the PUT (or POST) request payload from a slice of bytes.
 func ContentBytes(value []byte) Option {
 	return func(in *inputModel) {
 		in.content = bytes.NewReader(value)
@@ -165,11 +335,47 @@ func ContentBytes(value []byte) Option {
 	}
 }
 
-// Content specifies the PUT request payload from an io.ReadSeeker.
+// Content specifies the PUT (or POST) request payload from an io.ReadSeeker.
 func Content(value io.ReadSeeker) Option {
 	return func(in *inputModel) { in.content = value }
 }
 
+// StreamingSignature specifies that the PUT request payload be signed in chunks of the
+// provided size (in bytes) as it is sent, using the aws-chunked content encoding
+// (STREAMING-AWS4-HMAC-SHA256-PAYLOAD). This avoids reading the entire payload into
+// memory to calculate its checksum. A chunkSize <= 0 results in the default of 64 KB.
+// S3 requires every chunk (but the last) to be at least 8 KB.
+func StreamingSignature(chunkSize int) Option {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	return func(in *inputModel) { in.chunkSize = chunkSize }
+}
+
+// ContentTypePrefix allows a browser-based upload to specify any content type that begins with the prefix.
+// This option only applies to NewPresignedPost.
+func ContentTypePrefix(value string) Option {
+	return func(in *inputModel) { in.contentTypePrefix = value }
+}
+
+// ContentLengthRange restricts the size (in bytes) of a browser-based upload.
+// This option only applies to NewPresignedPost.
+func ContentLengthRange(min, max int64) Option {
+	return func(in *inputModel) {
+		in.contentLengthRange = true
+		in.contentLengthMin = min
+		in.contentLengthMax = max
+	}
+}
+
+// UnsignedPayload specifies that the request payload be excluded from the signature
+// (X-Amz-Content-Sha256: UNSIGNED-PAYLOAD), which means the payload is neither read
+// into memory nor hashed before sending. Only recommended for requests sent over TLS.
+// A POST payload is still read to calculate its Content-MD5 (unless one is specified).
+func UnsignedPayload() Option {
+	return func(in *inputModel) { in.unsignedPayload = true }
+}
+
 // ContentType specifies the Content Type of the payload/blob.
 // This option only applies to SignedPutRequest.
 func ContentType(value string) Option {
@@ -183,7 +389,8 @@ func ContentLength(value int64) Option {
 }
 
 // ContentMD5 specifies the MD5 checksum of the payload/blob.
-// This option only applies to SignedPutRequest.
+// This option only applies to PUT and POST requests. When omitted from
+// a POST request with a payload, the checksum is calculated automatically.
 func ContentMD5(value string) Option {
 	return func(in *inputModel) { in.contentMD5 = value }
 }
@@ -200,12 +407,86 @@ func ServerSideEncryption(value ServerSideEncryptionValue) Option {
 	return func(in *inputModel) { in.serverSideEncryption = value }
 }
 
+// PartSize specifies the size (in bytes) of each part sent by the Uploader (default: 8 MB).
+// S3 requires every part (but the last) to be at least 5 MB.
+// This option only applies to the Uploader.
+func PartSize(value int64) Option {
+	return func(in *inputModel) { in.partSize = value }
+}
+
+// Concurrency specifies the number of parts the Uploader sends simultaneously (default: 4).
+// This option only applies to the Uploader.
+func Concurrency(value int) Option {
+	return func(in *inputModel) { in.concurrency = value }
+}
+
+// PartRetries specifies the number of times the Uploader re-sends a failed part (default: 3).
+// This option only applies to the Uploader.
+func PartRetries(value int) Option {
+	if value < 0 {
+		value = 0
+	}
+	return func(in *inputModel) { in.partAttempts = value + 1 }
+}
+
+// SigningTrace specifies a callback that receives the intermediate values of the signature calculated
+// by NewRequest, NewPresignedURL, or Sign. Compare them to those reported by S3 (when it responds with
+// SignatureDoesNotMatch) with SigningDetails.Diff. Streamed chunk signatures aren't reported,
+// and SigV2 signatures (see SignatureV2) report only the string to sign and the signature.
+func SigningTrace(callback func(SigningDetails)) Option {
+	return func(in *inputModel) { in.signingTrace = callback }
+}
+
+// SignatureV2 specifies that NewRequest and NewPresignedURL sign by way of the legacy Signature Version 2
+// (HMAC-SHA1) for S3-compatible services (like older Ceph RGW) that don't support SigV4. Presigned URLs
+// carry the AWSAccessKeyId, Expires, and Signature query parameters. This option doesn't apply to
+// StreamingSignature, NewPresignedPost, or Sign, and takes precedence over SignatureV4A.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/RESTAuthentication.html
+func SignatureV2() Option {
+	return func(in *inputModel) { in.signatureV2 = true }
+}
+
+// SignatureV4A specifies that NewRequest and NewPresignedURL sign by way of SigV4A (AWS4-ECDSA-P256-SHA256),
+// as required by S3 Multi-Region Access Points, for the set of regions (by default "*", meaning all regions)
+// rather than the Region. Given the alias of a Multi-Region Access Point as the Bucket (e.g. "mfzwi23gnjvgw.mrap"),
+// requests (without an alternate Endpoint) go to <alias>.accesspoint.s3-global.amazonaws.com. This option
+// doesn't apply to StreamingSignature, NewPresignedPost, or Sign.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/MultiRegionAccessPointRequests.html
+func SignatureV4A(regionSet ...string) Option {
+	return func(in *inputModel) {
+		in.signatureV4A = true
+		in.regionSet = regionSet
+	}
+}
+
+// SignedHeaders specifies the (only) headers, along with the Host header and any headers added by Sign
+// itself, that Sign includes in the signature. By default the Host, Content-Type, Content-MD5, and
+// X-Amz-* headers are signed. This option only applies to Sign.
+func SignedHeaders(names ...string) Option {
+	return func(in *inputModel) { in.signedHeaders = append(append([]string{}, in.signedHeaders...), names...) }
+}
+
+// QuerySignature specifies that Sign add the signature to the query string (as with a presigned URL)
+// rather than the Authorization header, valid for the period specified by ExpiresIn or ExpireTime.
+// This option only applies to Sign.
+func QuerySignature() Option {
+	return func(in *inputModel) { in.querySignature = true }
+}
+
 // Timestamp specifies the timestamp to be included as the X-Amz-Date as well
 // as for use in time based calculations. Helpful for testing.
 func Timestamp(value time.Time) Option {
 	return func(in *inputModel) { in.now = value }
 }
 
+type addressingStyle int
+
+const (
+	defaultAddressing addressingStyle = iota
+	pathStyleAddressing
+	virtualHostedStyleAddressing
+)
+
 type ServerSideEncryptionValue string
 
 const (
@@ -1,6 +1,7 @@
 package s3
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -33,7 +34,7 @@ func (this *OptionsFixture) TestMissingMethod() {
 }
 
 func (this *OptionsFixture) TestInvalidMethod() {
-	request, err := NewRequest("POST", Bucket("bucket"), Key("key"))
+	request, err := NewRequest("PATCH", Bucket("bucket"), Key("key"))
 	this.So(err, should.Equal, ErrInvalidRequestMethod)
 	this.So(request, should.BeNil)
 }
@@ -56,6 +57,59 @@ func (this *OptionsFixture) Test_PUT_MissingContent() {
 	this.So(request, should.BeNil)
 }
 
+func (this *OptionsFixture) Test_DELETE() {
+	request, err := NewRequest(DELETE, Region("us-east-1"), Bucket("bucket"), Key("key"))
+	this.So(err, should.BeNil)
+	this.So(request.Method, should.Equal, DELETE)
+	this.So(request.URL.String(), should.Equal, "https://s3.amazonaws.com/bucket/key")
+	this.So(request.Header.Get("X-Amz-Content-Sha256"), should.Equal, hashSHA256(nil))
+	this.So(request.Header.Get("Content-MD5"), should.BeBlank)
+}
+
+func (this *OptionsFixture) Test_DELETE_VersionID() {
+	request, err := NewRequest(DELETE, Bucket("bucket"), Key("key"), VersionID("3/L4kqtJlcpXroDTDmJ+rmSpXd3dIbrHY"))
+	this.So(err, should.BeNil)
+	this.So(request.URL.Query().Get("versionId"), should.Equal, "3/L4kqtJlcpXroDTDmJ+rmSpXd3dIbrHY")
+}
+
+func (this *OptionsFixture) Test_DELETE_MissingKey() {
+	request, err := NewRequest(DELETE, Bucket("bucket"))
+	this.So(err, should.Equal, ErrKeyMissing)
+	this.So(request, should.BeNil)
+}
+
+func (this *OptionsFixture) Test_POST_BucketSubResourceWithContent() {
+	content := "<Delete><Object><Key>key</Key></Object></Delete>"
+	request, err := NewRequest(POST, Region("us-east-1"), Bucket("bucket"), SubResource("delete"), ContentString(content))
+	this.So(err, should.BeNil)
+	this.So(request.URL.String(), should.Equal, "https://s3.amazonaws.com/bucket/?delete=")
+	this.So(request.Header.Get("Content-MD5"), should.Equal, hashMD5([]byte(content)))
+	this.So(request.Header.Get("X-Amz-Content-Sha256"), should.Equal, hashSHA256([]byte(content)))
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "content-md5")
+	all, _ := ioutil.ReadAll(request.Body)
+	this.So(string(all), should.Equal, content)
+}
+
+func (this *OptionsFixture) Test_POST_MissingKey() {
+	request, err := NewRequest(POST, Bucket("bucket"), SubResource("uploads"))
+	this.So(err, should.Equal, ErrKeyMissing)
+	this.So(request, should.BeNil)
+}
+
+func (this *OptionsFixture) Test_POST_ExplicitContentMD5() {
+	request, err := NewRequest(POST, Bucket("bucket"), SubResource("delete"), ContentString("hi"), ContentMD5("abcdef01"))
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Content-MD5"), should.Equal, "abcdef01")
+}
+
+func (this *OptionsFixture) Test_POST_WithoutContent() {
+	request, err := NewRequest(POST, Region("us-east-1"), Bucket("bucket"), Key("key"), SubResource("uploads"))
+	this.So(err, should.BeNil)
+	this.So(request.URL.String(), should.Equal, "https://s3.amazonaws.com/bucket/key?uploads=")
+	this.So(request.Header.Get("Content-MD5"), should.BeBlank)
+	this.So(request.Header.Get("X-Amz-Content-Sha256"), should.Equal, hashSHA256(nil))
+}
+
 func (this *OptionsFixture) TestZeroLengthKey() {
 	request, err := NewRequest(GET, Bucket("bucket"), Key(""))
 	this.So(err, should.Equal, ErrKeyMissing)
@@ -95,6 +149,40 @@ func (this *OptionsFixture) TestEndpoint() {
 	this.So(request.URL.Path, should.Equal, "/bucket/key")
 }
 
+func (this *OptionsFixture) TestInvalidEndpoint() {
+	for _, endpoint := range []string{"localhost:9000", "localhost", "ftp://localhost:9000", "http://", "http://local host"} {
+		request, err := NewRequest(GET, Endpoint(endpoint), Bucket("bucket"), Key("key"))
+		this.So(request, should.BeNil)
+		this.So(errors.Is(err, ErrInvalidEndpoint), should.BeTrue)
+
+		address, err := NewPresignedGet(Endpoint(endpoint), Bucket("bucket"), Key("key"), Credentials("access", "secret"))
+		this.So(address, should.BeBlank)
+		this.So(errors.Is(err, ErrInvalidEndpoint), should.BeTrue)
+
+		_, err = NewPresignedPost(Endpoint(endpoint), Bucket("bucket"), Key("key"), Credentials("access", "secret"), ExpiresIn(time.Hour))
+		this.So(errors.Is(err, ErrInvalidEndpoint), should.BeTrue)
+	}
+}
+
+func (this *OptionsFixture) TestVirtualHostedStyle() {
+	request, err := NewRequest(GET, Region("us-west-1"), Bucket("bucket"), Key("key"), VirtualHostedStyle())
+	this.So(err, should.BeNil)
+	this.So(request.URL.String(), should.Equal, "https://bucket.s3-us-west-1.amazonaws.com/key")
+}
+
+func (this *OptionsFixture) TestEndpointWithVirtualHostedStyle() {
+	request, err := NewRequest(GET, Endpoint("http://localhost:9000"), Bucket("bucket"), Key("key"), VirtualHostedStyle())
+	this.So(err, should.BeNil)
+	this.So(request.URL.String(), should.Equal, "http://bucket.localhost:9000/key")
+}
+
+func (this *OptionsFixture) TestKeyIsEncodedInPath() {
+	request, err := NewRequest(GET, Region("us-east-1"), Bucket("bucket"), Key("a b/c+d?e"))
+	this.So(err, should.BeNil)
+	this.So(request.URL.String(), should.Equal, "https://s3.amazonaws.com/bucket/a%20b/c%2Bd%3Fe")
+	this.So(request.URL.Path, should.Equal, "/bucket/a b/c+d?e")
+}
+
 func (this *OptionsFixture) TestExpireTimeAppearsInHeader() {
 	now := time.Now()
 	requestWithExpiration, _ := NewRequest(GET, Bucket("bucket"), Key("key"), ExpireTime(now))
@@ -156,6 +244,55 @@ func (this *OptionsFixture) TestPUT_Content() {
 	this.So(string(all), should.Equal, "hi")
 }
 
+func (this *OptionsFixture) TestPUT_StreamingSignature() {
+	content := strings.Repeat("a", 10*1024)
+	put, err := NewRequest(PUT, Bucket("bucket"), Key("key"),
+		Content(strings.NewReader(content)), ContentEncoding("gzip"), StreamingSignature(8*1024))
+
+	this.So(err, should.BeNil)
+	this.So(put.Header.Get("X-Amz-Content-Sha256"), should.Equal, "STREAMING-AWS4-HMAC-SHA256-PAYLOAD")
+	this.So(put.Header.Get("X-Amz-Decoded-Content-Length"), should.Equal, "10240")
+	this.So(put.Header.Get("Content-Encoding"), should.Equal, "aws-chunked,gzip")
+	this.So(put.ContentLength, should.Equal, chunkedContentLength(10*1024, 8*1024))
+	this.So(put.Header.Get("Authorization"), should.ContainSubstring, "x-amz-decoded-content-length")
+
+	all, _ := ioutil.ReadAll(put.Body)
+	this.So(len(all), should.Equal, put.ContentLength)
+	this.So(string(all), should.StartWith, "2000;chunk-signature=")
+	this.So(string(all), should.ContainSubstring, "\r\n800;chunk-signature=")
+	this.So(string(all), should.ContainSubstring, "\r\n0;chunk-signature=")
+}
+
+func (this *OptionsFixture) TestGET_StreamingSignatureIgnored() {
+	request, err := NewRequest(GET, Bucket("bucket"), Key("key"), StreamingSignature(0))
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("X-Amz-Content-Sha256"), should.Equal, hashSHA256(nil))
+}
+
+func (this *OptionsFixture) TestPUT_UnsignedPayload() {
+	content := &unbufferedReader{Reader: strings.NewReader("hi")}
+	put, err := NewRequest(PUT, Bucket("bucket"), Key("key"), Content(content), ContentLength(2), UnsignedPayload())
+
+	this.So(err, should.BeNil)
+	this.So(content.reads, should.Equal, 0)
+	this.So(put.Header.Get("X-Amz-Content-Sha256"), should.Equal, "UNSIGNED-PAYLOAD")
+	this.So(put.Header.Get("Authorization"), should.ContainSubstring, "x-amz-content-sha256")
+	this.So(put.ContentLength, should.Equal, 2)
+	all, _ := ioutil.ReadAll(put.Body)
+	this.So(string(all), should.Equal, "hi")
+}
+
+func (this *OptionsFixture) TestPOST_UnsignedPayloadStillHasContentMD5() {
+	content := "<Delete><Object><Key>key</Key></Object></Delete>"
+	post, err := NewRequest(POST, Bucket("bucket"), SubResource("delete"), ContentString(content), UnsignedPayload())
+
+	this.So(err, should.BeNil)
+	this.So(post.Header.Get("X-Amz-Content-Sha256"), should.Equal, "UNSIGNED-PAYLOAD")
+	this.So(post.Header.Get("Content-MD5"), should.Equal, hashMD5([]byte(content)))
+	all, _ := ioutil.ReadAll(post.Body)
+	this.So(string(all), should.Equal, content)
+}
+
 func (this *OptionsFixture) TestStorageAddress() {
 	address := &url.URL{Scheme: "https", Host: "bucket.s3.us-west-1.amazonaws.com", Path: "/key", RawPath: "/key"}
 	request, _ := NewRequest(GET, StorageAddress(address))
@@ -194,3 +331,13 @@ func (this *OptionsFixture) TestTimestamp() {
 	request, _ := NewRequest(GET, Region("r"), Bucket("b"), Key("k"), Timestamp(now))
 	this.So(request.Header.Get("X-Amz-Date"), should.Equal, now.Format(timeFormatV4))
 }
+
+type unbufferedReader struct {
+	*strings.Reader
+	reads int
+}
+
+func (this *unbufferedReader) Read(p []byte) (int, error) {
+	this.reads++
+	return this.Reader.Read(p)
+}
@@ -0,0 +1,111 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// PresignedPost contains what a browser needs in order to upload directly to S3
+// with an HTML form: the form's action URL and its (hidden) input fields. The file
+// itself must be the last field of the multipart/form-data request body.
+type PresignedPost struct {
+	URL    string
+	Fields map[string]string
+}
+
+type postPresigner struct {
+	input  *inputModel
+	fields map[string]string
+}
+
+func newPostPresigner(input *inputModel) *postPresigner {
+	return &postPresigner{input: input, fields: make(map[string]string)}
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+func (this *postPresigner) Generate() (PresignedPost, error) {
+	policy, err := this.task1_composePolicy()
+	if err != nil {
+		return PresignedPost{}, err
+	}
+	encodedPolicy := base64.StdEncoding.EncodeToString(policy)
+	this.fields[postFieldPolicy] = encodedPolicy
+	this.fields[postFieldSignature] = this.task2_calculateSignature(encodedPolicy)
+
+	address := this.input.buildBucketAddress(this.input.usePathStyle(len(this.input.endpoint) > 0))
+	return PresignedPost{URL: address.String(), Fields: this.fields}, nil
+}
+
+func (this *postPresigner) task1_composePolicy() ([]byte, error) {
+	conditions := []interface{}{
+		map[string]string{"bucket": this.input.bucket},
+	}
+	if len(this.input.keyPrefix) > 0 {
+		this.fields[postFieldKey] = this.input.keyPrefix + "${filename}"
+		conditions = append(conditions, []string{"starts-with", "$key", this.input.keyPrefix})
+	} else {
+		conditions = append(conditions, this.exactCondition(postFieldKey, this.input.key))
+	}
+	if len(this.input.contentType) > 0 {
+		conditions = append(conditions, this.exactCondition(postFieldContentType, this.input.contentType))
+	} else if len(this.input.contentTypePrefix) > 0 {
+		conditions = append(conditions, []string{"starts-with", "$" + postFieldContentType, this.input.contentTypePrefix})
+	}
+	if this.input.contentLengthRange {
+		conditions = append(conditions, []interface{}{"content-length-range",
+			this.input.contentLengthMin, this.input.contentLengthMax})
+	}
+	if len(this.input.serverSideEncryption) > 0 {
+		conditions = append(conditions, this.exactCondition(postFieldServerSideEncryption, string(this.input.serverSideEncryption)))
+	}
+	if token := this.input.credential().SecurityToken; len(token) > 0 {
+		conditions = append(conditions, this.exactCondition(postFieldSecurityToken, token))
+	}
+	conditions = append(conditions,
+		this.exactCondition(postFieldAlgorithm, awsV4SignatureAlgorithm),
+		this.exactCondition(postFieldCredential, this.input.fullCredentialScope()),
+		this.exactCondition(postFieldDate, this.input.timestampV4()),
+	)
+
+	return json.Marshal(map[string]interface{}{
+		"expiration": this.input.expiration().UTC().Format(postExpirationFormat),
+		"conditions": conditions,
+	})
+}
+
+// exactCondition requires that the form field have the value, which is also included in the fields.
+func (this *postPresigner) exactCondition(field, value string) map[string]string {
+	this.fields[field] = value
+	return map[string]string{field: value}
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-post-example.html
+func (this *postPresigner) task2_calculateSignature(encodedPolicy string) string {
+	secret := this.input.credential().SecretAccessKey
+	signingKey := deriveSigningKeyV4(secret, timestampDateV4(this.input.timestampV4()), this.input.region, "s3")
+	return hex.EncodeToString(hmacSHA256(signingKey, encodedPolicy))
+}
+
+// expiration is that of POST policies and of SigV2 presigned URLs, which (unlike SigV4) state the instant.
+func (this *inputModel) expiration() time.Time {
+	if !this.expireTime.IsZero() {
+		return this.expireTime
+	}
+	return this.now.Add(this.expiresIn)
+}
+
+const (
+	postFieldKey                  = "key"
+	postFieldContentType          = "Content-Type"
+	postFieldServerSideEncryption = "x-amz-server-side-encryption"
+	postFieldSecurityToken        = "x-amz-security-token"
+	postFieldAlgorithm            = "x-amz-algorithm"
+	postFieldCredential           = "x-amz-credential"
+	postFieldDate                 = "x-amz-date"
+	postFieldPolicy               = "policy"
+	postFieldSignature            = "x-amz-signature"
+
+	postExpirationFormat = "2006-01-02T15:04:05.000Z"
+)
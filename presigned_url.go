@@ -0,0 +1,226 @@
+package s3
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type presigner struct {
+	input            *inputModel
+	address          *url.URL
+	canonicalHeaders string
+	signedHeaders    string
+}
+
+func newPresigner(input *inputModel) *presigner {
+	address := input.buildAddress(input.usePathStyle(len(input.endpoint) > 0))
+	canonicalHeaders, signedHeaders := canonicalizeHeaders(presignedHeaders(input, address.Host), true)
+	return &presigner{
+		input:            input,
+		address:          address,
+		canonicalHeaders: canonicalHeaders,
+		signedHeaders:    signedHeaders,
+	}
+}
+
+// presignedHeaders are the headers that a request made with the URL must include.
+func presignedHeaders(input *inputModel, host string) http.Header {
+	headers := make(http.Header)
+	headers.Set("Host", host)
+	if len(input.contentType) > 0 {
+		headers.Set("Content-Type", input.contentType)
+	}
+	if len(input.contentMD5) > 0 {
+		headers.Set("Content-MD5", input.contentMD5)
+	}
+	if len(input.serverSideEncryption) > 0 {
+		headers.Set("X-Amz-Server-Side-Encryption", string(input.serverSideEncryption))
+	}
+	return headers
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func (this *presigner) GenerateURL() (string, error) {
+	if this.input.signatureV2 {
+		return this.generateURLV2(), nil
+	}
+	var (
+		canonicalQuery   = this.task0_buildCanonicalQueryString()
+		canonicalRequest = this.task1_composeCanonicalRequest(canonicalQuery)
+		stringToSign     = this.task2_composeStringToSign(canonicalRequest)
+	)
+	signature, err := this.task3_calculateSignature(stringToSign)
+	if err != nil {
+		return "", err
+	}
+	this.input.trace(SigningDetails{
+		CanonicalRequest: canonicalRequest,
+		StringToSign:     stringToSign,
+		SignedHeaders:    this.signedHeaders,
+		CredentialScope:  this.credentialScope(),
+		Signature:        signature,
+	})
+	return this.assembleURL(canonicalQuery, signature)
+}
+
+func (this *presigner) task0_buildCanonicalQueryString() url.Values {
+	query := make(url.Values)
+	for key, values := range this.input.query {
+		query[key] = values
+	}
+	query.Set(headerAlgorithm, this.algorithm())
+	query.Set(headerCredential, this.input.credential().AccessKeyID+"/"+this.credentialScope())
+	query.Set(headerDate, this.input.timestampV4())
+	query.Set(headerExpires, this.input.expiresInSeconds())
+	query.Set(headerSignedHeaders, this.signedHeaders)
+	if this.input.signatureV4A {
+		query.Set(headerRegionSet, regionSetV4A(this.input.regionSet))
+	}
+	if token := this.input.credential().SecurityToken; len(token) > 0 {
+		query.Set(headerSecurityToken, token) // temporary (STS/IAM role) credentials
+	}
+	return query
+}
+
+func (this *presigner) task1_composeCanonicalRequest(query url.Values) string {
+	return join("\n",
+		this.input.method,
+		normalizeURI(this.address.Path),
+		normalizeQuery(query),
+		this.canonicalHeaders, // each header is followed by a newline, leaving a blank line
+		this.signedHeaders,
+		awsV4UnsignedPayload,
+	)
+}
+
+func (this *presigner) task2_composeStringToSign(canonicalRequest string) string {
+	return join("\n",
+		this.algorithm(),
+		this.input.timestampV4(),
+		this.credentialScope(),
+		hashSHA256([]byte(canonicalRequest)),
+	)
+}
+
+func (this *presigner) task3_calculateSignature(stringToSign string) (string, error) {
+	if this.input.signatureV4A {
+		return signV4A(this.input.credential(), stringToSign)
+	}
+	secret := this.input.credential().SecretAccessKey
+	signingKey := deriveSigningKeyV4(secret, timestampDateV4(this.input.timestampV4()), this.input.region, "s3")
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign)), nil
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/RESTAuthentication.html#RESTAuthenticationQueryStringAuth
+func (this *presigner) generateURLV2() string {
+	credentials := this.input.credential()
+	headers := presignedHeaders(this.input, this.address.Host)
+	query := make(url.Values)
+	for key, values := range this.input.query {
+		query[key] = values
+	}
+	if token := credentials.SecurityToken; len(token) > 0 {
+		headers.Set(headerSecurityToken, token) // signed as an x-amz-* header
+		query.Set(headerSecurityToken, token)
+	}
+	expires := formatInt64(this.input.expiration().Unix())
+	stringToSign := stringToSignV2(this.input.method, headers, expires,
+		canonicalResourceV2(this.input.bucket, this.input.key, this.input.query))
+	signature := signV2(credentials.SecretAccessKey, stringToSign)
+	this.input.trace(SigningDetails{StringToSign: stringToSign, Signature: signature})
+
+	query.Set(queryAccessKeyIDV2, credentials.AccessKeyID)
+	query.Set(queryExpiresV2, expires)
+	query.Set(querySignatureV2, signature)
+	address := *this.address
+	address.RawQuery = normalizeQuery(query)
+	return address.String()
+}
+
+func (this *presigner) algorithm() string {
+	if this.input.signatureV4A {
+		return awsV4ASignatureAlgorithm
+	}
+	return awsV4SignatureAlgorithm
+}
+
+// credentialScope omits the region of SigV4A signatures (which instead state the X-Amz-Region-Set).
+func (this *presigner) credentialScope() string {
+	if this.input.signatureV4A {
+		return v4aCredentialScope(timestampDateV4(this.input.timestampV4()), awsS3Service)
+	}
+	return this.input.credentialScope()
+}
+
+func (this *presigner) assembleURL(canonicalQuery url.Values, signature string) (string, error) {
+	address := *this.address
+	address.RawQuery = normalizeQuery(canonicalQuery) +
+		"&" + headerSignature +
+		"=" + signature
+	return address.String(), nil
+}
+
+// PresignedURL describes the signature of a presigned URL, along with the bucket and key it grants access to.
+type PresignedURL struct {
+	AccessKeyID     string
+	CredentialScope string // <date>/<region>/<service>/aws4_request
+	Region          string
+	Service         string
+	Date            time.Time // when the URL was signed
+	Expiration      time.Time
+	SignedHeaders   []string
+	Signature       string
+	SecurityToken   string
+
+	Endpoint string // only for alternate (non-AWS) endpoints
+	Bucket   string
+	Key      string
+}
+
+// ParsePresignedURL extracts the (unverified) signature details of a URL generated by NewPresignedURL (or
+// signed by Sign with the QuerySignature option). The error for a malformed URL is a *VerificationError.
+func ParsePresignedURL(address *url.URL) (PresignedURL, error) {
+	query := address.Query()
+	authorization, err := parseV4AuthorizationQuery(query)
+	if err != nil {
+		return PresignedURL{}, err
+	}
+	endpoint, _, bucket, key := EndpointRegionBucketKey(address)
+	return PresignedURL{
+		AccessKeyID:     authorization.accessKeyID,
+		CredentialScope: join("/", authorization.date, authorization.region, authorization.service, awsV4CredentialScopeTerminationString),
+		Region:          authorization.region,
+		Service:         authorization.service,
+		Date:            authorization.timestamp,
+		Expiration:      authorization.timestamp.Add(authorization.expires),
+		SignedHeaders:   authorization.signedHeaders,
+		Signature:       authorization.signature,
+		SecurityToken:   query.Get(headerSecurityToken),
+		Endpoint:        endpoint,
+		Bucket:          bucket,
+		Key:             key,
+	}, nil
+}
+
+// VerifyPresignedURL authenticates a URL generated by NewPresignedURL for the method with the secret access
+// key of its signer by way of Verify. Any content type, content MD5, or server-side encryption specified in
+// the options supplies the value of the corresponding signed header. The Timestamp option stands in for now.
+func VerifyPresignedURL(method string, address *url.URL, secret string, options ...Option) error {
+	in := newBareInput(options)
+	headers := presignedHeaders(in, address.Host)
+	headers.Del("Host")
+	request := &http.Request{Method: method, URL: address, Host: address.Host, Header: headers}
+	return Verify(request, func(string) (string, error) { return secret, nil }, options...)
+}
+
+const (
+	headerAlgorithm     = "X-Amz-Algorithm"
+	headerCredential    = "X-Amz-Credential"
+	headerDate          = "X-Amz-Date"
+	headerExpires       = "X-Amz-Expires"
+	headerSignedHeaders = "X-Amz-SignedHeaders"
+	headerSignature     = "X-Amz-Signature"
+	headerSecurityToken = "X-Amz-Security-Token"
+)
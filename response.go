@@ -0,0 +1,66 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// ResponseError describes the error document returned by S3 for failed requests.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html
+type ResponseError struct {
+	XMLName    xml.Name `xml:"Error"`
+	StatusCode int      `xml:"-"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	RequestID  string   `xml:"RequestId"`
+	HostID     string   `xml:"HostId"`
+
+	// Reported with SignatureDoesNotMatch (see SigningDetails.Diff):
+	CanonicalRequest string `xml:"CanonicalRequest"`
+	StringToSign     string `xml:"StringToSign"`
+}
+
+func (this *ResponseError) Error() string {
+	return fmt.Sprintf("s3 responded with %d %s: %s", this.StatusCode, this.Code, this.Message)
+}
+
+func newResponseError(statusCode int, body []byte) *ResponseError {
+	failure := &ResponseError{}
+	if err := xml.Unmarshal(body, failure); err != nil || len(failure.Code) == 0 {
+		failure.Code = http.StatusText(statusCode)
+	}
+	failure.StatusCode = statusCode
+	return failure
+}
+
+// decodeResponse reads the response body into the result (which may be nil), or
+// returns a *ResponseError when S3 reports a failure (even with a 200 OK status).
+func decodeResponse(response *http.Response, result interface{}) error {
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK || isErrorDocument(body) {
+		return newResponseError(response.StatusCode, body)
+	}
+	if result == nil {
+		return nil
+	}
+	return xml.Unmarshal(body, result)
+}
+
+func isErrorDocument(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if element, ok := token.(xml.StartElement); ok {
+			return element.Name.Local == "Error"
+		}
+	}
+}
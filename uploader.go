@@ -0,0 +1,260 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HTTPClient sends HTTP requests (*http.Client satisfies this interface).
+type HTTPClient interface {
+	Do(request *http.Request) (*http.Response, error)
+}
+
+// Uploader sends the contents of an io.Reader (of any length) to S3 as a multipart upload.
+// Parts are read sequentially and sent concurrently, so no more than a few parts are held
+// in memory at any given time (see the PartSize and Concurrency options).
+type Uploader struct {
+	client  HTTPClient
+	options []Option
+	backoff time.Duration
+}
+
+// NewUploader creates an Uploader that sends requests with the provided client. The provided
+// options (credentials, region, bucket, endpoint, etc...) apply to every upload.
+func NewUploader(client HTTPClient, options ...Option) *Uploader {
+	return &Uploader{
+		client:  client,
+		options: options,
+		backoff: defaultPartRetryBackoff,
+	}
+}
+
+// Upload initiates a multipart upload, sends the body in parts (retrying failed parts),
+// and completes the upload. Should any part (or the completion) ultimately fail the upload is aborted.
+// The provided options are applied after (and so take precedence over) those provided
+// to NewUploader and must specify the key (if not already specified).
+func (this *Uploader) Upload(ctx context.Context, body io.Reader, options ...Option) (result CompleteMultipartUploadResult, err error) {
+	options = append(append([]Option{}, this.options...), options...)
+
+	uploadID, err := this.create(ctx, options)
+	if err != nil {
+		return result, err
+	}
+
+	parts, err := this.uploadParts(ctx, uploadID, body, newBareInput(options), options)
+	if err != nil {
+		_ = this.abort(uploadID, options)
+		return result, err
+	}
+
+	result, err = this.complete(ctx, uploadID, parts, options)
+	if err != nil {
+		_ = this.abort(uploadID, options)
+	}
+	return result, err
+}
+
+func (this *Uploader) create(ctx context.Context, options []Option) (string, error) {
+	request, err := NewCreateMultipartUploadRequest(options...)
+	response, err := this.send(ctx, request, err)
+	if err != nil {
+		return "", err
+	}
+	defer closeHandle(response.Body)
+
+	result, err := ParseCreateMultipartUploadResponse(response)
+	return result.UploadID, err
+}
+
+func (this *Uploader) uploadParts(ctx context.Context, uploadID string, body io.Reader, config *inputModel, options []Option) ([]CompletedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		waiter  sync.WaitGroup
+		mutex   sync.Mutex
+		parts   []CompletedPart
+		failure error
+	)
+	fail := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if failure == nil {
+			failure = err
+			cancel()
+		}
+	}
+
+	jobs := make(chan uploadPartJob)
+	for i := 0; i < config.uploadConcurrency(); i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			for job := range jobs {
+				part, err := this.uploadPart(ctx, uploadID, job, config.uploadPartAttempts(), options)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				mutex.Lock()
+				parts = append(parts, part)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	partSize := config.uploadPartSize()
+	for number := minimumPartNumber; ctx.Err() == nil; number++ {
+		content := make([]byte, partSize)
+		length, err := io.ReadFull(body, content)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			fail(err)
+			break
+		}
+		if length == 0 && number > minimumPartNumber {
+			break // an empty stream is uploaded as a single, empty part
+		}
+		if number > maximumPartNumber {
+			fail(ErrTooManyParts)
+			break
+		}
+
+		select {
+		case jobs <- uploadPartJob{number: number, content: content[:length]}:
+		case <-ctx.Done():
+		}
+
+		if int64(length) < partSize {
+			break
+		}
+	}
+	close(jobs)
+	waiter.Wait()
+
+	if failure == nil {
+		failure = ctx.Err()
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (this *Uploader) uploadPart(ctx context.Context, uploadID string, job uploadPartJob, attempts int, options []Option) (part CompletedPart, err error) {
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(this.backoff * time.Duration(attempt)):
+			case <-ctx.Done():
+				return part, ctx.Err()
+			}
+		}
+		part, err = this.tryUploadPart(ctx, uploadID, job, options)
+		if err == nil || !retryable(err) {
+			break
+		}
+	}
+	return part, err
+}
+
+func (this *Uploader) tryUploadPart(ctx context.Context, uploadID string, job uploadPartJob, options []Option) (CompletedPart, error) {
+	request, err := NewUploadPartRequest(uploadID, job.number, CompositeOption(options...), ContentBytes(job.content))
+	response, err := this.send(ctx, request, err)
+	if err != nil {
+		return CompletedPart{}, err
+	}
+	defer closeHandle(response.Body)
+
+	return ParseUploadPartResponse(response, job.number)
+}
+
+func (this *Uploader) complete(ctx context.Context, uploadID string, parts []CompletedPart, options []Option) (result CompleteMultipartUploadResult, err error) {
+	request, err := NewCompleteMultipartUploadRequest(uploadID, parts, options...)
+	response, err := this.send(ctx, request, err)
+	if err != nil {
+		return result, err
+	}
+	defer closeHandle(response.Body)
+
+	return ParseCompleteMultipartUploadResponse(response)
+}
+
+// abort discards the uploaded parts regardless of whether the upload's context has been cancelled.
+func (this *Uploader) abort(uploadID string, options []Option) error {
+	request, err := NewAbortMultipartUploadRequest(uploadID, options...)
+	response, err := this.send(context.Background(), request, err)
+	if err != nil {
+		return err
+	}
+	closeHandle(response.Body)
+	return nil
+}
+
+func (this *Uploader) send(ctx context.Context, request *http.Request, err error) (*http.Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	return this.client.Do(request.WithContext(ctx))
+}
+
+type uploadPartJob struct {
+	number  int
+	content []byte
+}
+
+// retryable indicates whether a failed part should be sent again. Only failures of the
+// transport and those reported by S3 as transient (5xx, or a RequestTimeout) are; any other
+// failure (of the options, credentials, or signature, for instance) would just recur.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var failure *ResponseError
+	if errors.As(err, &failure) {
+		return failure.StatusCode >= http.StatusInternalServerError || failure.Code == "RequestTimeout"
+	}
+	var request *url.Error // returned by the http.Client for any failure, so only what it wraps tells
+	if errors.As(err, &request) {
+		err = request.Err
+	}
+	var network net.Error
+	return errors.As(err, &network) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (this *inputModel) uploadPartSize() int64 {
+	if this.partSize <= 0 {
+		return defaultPartSize
+	}
+	return this.partSize
+}
+
+func (this *inputModel) uploadConcurrency() int {
+	if this.concurrency <= 0 {
+		return defaultConcurrency
+	}
+	return this.concurrency
+}
+
+func (this *inputModel) uploadPartAttempts() int {
+	if this.partAttempts <= 0 {
+		return defaultPartRetries + 1
+	}
+	return this.partAttempts
+}
+
+const (
+	defaultPartSize         = 8 * 1024 * 1024
+	defaultConcurrency      = 4
+	defaultPartRetries      = 3
+	defaultPartRetryBackoff = time.Millisecond * 250
+)
+
+var ErrTooManyParts = errors.New("the content exceeds the maximum number of parts (10000); increase the part size")
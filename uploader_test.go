@@ -0,0 +1,235 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smarty/assertions/should"
+	"github.com/smarty/gunit"
+)
+
+func TestUploaderFixture(t *testing.T) {
+	gunit.Run(new(UploaderFixture), t)
+}
+
+type UploaderFixture struct {
+	*gunit.Fixture
+	s3       *FakeMultipartS3
+	server   *httptest.Server
+	uploader *Uploader
+}
+
+func (this *UploaderFixture) Setup() {
+	this.s3 = NewFakeMultipartS3()
+	this.server = httptest.NewServer(this.s3)
+	this.uploader = NewUploader(this.server.Client(),
+		Credentials("access", "secret"),
+		Endpoint(this.server.URL),
+		Bucket("bucket"),
+		PartSize(4),
+		Concurrency(3),
+		PartRetries(2),
+	)
+	this.uploader.backoff = 0
+}
+func (this *UploaderFixture) Teardown() {
+	this.server.Close()
+}
+
+func (this *UploaderFixture) TestUploadInParts() {
+	result, err := this.uploader.Upload(context.Background(), strings.NewReader("abcdefghijklmnopqrstuvwxyz"), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(result.ETag, should.Equal, `"complete"`)
+	this.So(this.s3.completed, should.Equal, "abcdefghijklmnopqrstuvwxyz")
+	this.So(this.s3.partCount, should.Equal, 7)
+	this.So(this.s3.aborted, should.BeFalse)
+}
+
+func (this *UploaderFixture) TestUploadEmptyContent() {
+	_, err := this.uploader.Upload(context.Background(), strings.NewReader(""), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(this.s3.partCount, should.Equal, 1)
+	this.So(this.s3.completed, should.Equal, "")
+}
+
+func (this *UploaderFixture) TestFailedPartIsRetried() {
+	this.s3.failures["3"] = 2
+
+	_, err := this.uploader.Upload(context.Background(), strings.NewReader("abcdefghijklmnopqrstuvwxyz"), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(this.s3.completed, should.Equal, "abcdefghijklmnopqrstuvwxyz")
+	this.So(this.s3.attempts["3"], should.Equal, 3)
+}
+
+func (this *UploaderFixture) TestPartTimedOutIsRetried() {
+	this.s3.failures["3"] = 2
+	this.s3.failureStatus, this.s3.failureCode = http.StatusBadRequest, "RequestTimeout"
+
+	_, err := this.uploader.Upload(context.Background(), strings.NewReader("abcdefghijklmnopqrstuvwxyz"), Key("key"))
+
+	this.So(err, should.BeNil)
+	this.So(this.s3.attempts["3"], should.Equal, 3)
+}
+
+func (this *UploaderFixture) TestPartRejectedIsNotRetried() {
+	this.s3.failures["3"] = 1
+	this.s3.failureStatus, this.s3.failureCode = http.StatusForbidden, "AccessDenied"
+
+	_, err := this.uploader.Upload(context.Background(), strings.NewReader("abcdefghijklmnopqrstuvwxyz"), Key("key"))
+
+	this.So(err, should.NotBeNil)
+	this.So(this.s3.attempts["3"], should.Equal, 1)
+	this.So(this.s3.aborted, should.BeTrue)
+}
+
+func (this *UploaderFixture) TestRetryableFailures() {
+	this.So(retryable(&ResponseError{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown"}), should.BeTrue)
+	this.So(retryable(&ResponseError{StatusCode: http.StatusBadRequest, Code: "RequestTimeout"}), should.BeTrue)
+	this.So(retryable(&url.Error{Op: "Put", URL: "https://bucket.s3.amazonaws.com/key", Err: io.ErrUnexpectedEOF}), should.BeTrue)
+	this.So(retryable(&url.Error{Op: "Put", URL: "https://bucket.s3.amazonaws.com/key", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}), should.BeTrue)
+
+	this.So(retryable(&ResponseError{StatusCode: http.StatusBadRequest, Code: "InvalidArgument"}), should.BeFalse)
+	this.So(retryable(&url.Error{Op: "Put", URL: "https://bucket.s3.amazonaws.com/key", Err: context.Canceled}), should.BeFalse)
+	this.So(retryable(fmt.Errorf("%w: %w", ErrCredentialsExpired, errors.New("source"))), should.BeFalse)
+	this.So(retryable(ErrKeyMissing), should.BeFalse)
+	this.So(retryable(errors.New("read failure")), should.BeFalse)
+}
+
+func (this *UploaderFixture) TestPartFailingTooOftenAbortsUpload() {
+	this.s3.failures["3"] = 3
+
+	_, err := this.uploader.Upload(context.Background(), strings.NewReader("abcdefghijklmnopqrstuvwxyz"), Key("key"))
+
+	failure, ok := err.(*ResponseError)
+	if this.So(ok, should.BeTrue) {
+		this.So(failure.Code, should.Equal, "InternalError")
+	}
+	this.So(this.s3.aborted, should.BeTrue)
+	this.So(this.s3.completed, should.BeBlank)
+}
+
+func (this *UploaderFixture) TestReadFailureAbortsUpload() {
+	readErr := errors.New("read failure")
+
+	_, err := this.uploader.Upload(context.Background(), &failingReader{err: readErr}, Key("key"))
+
+	this.So(err, should.Equal, readErr)
+	this.So(this.s3.aborted, should.BeTrue)
+}
+
+func (this *UploaderFixture) TestFailedCompletionAbortsUpload() {
+	this.s3.completionFails = true
+
+	_, err := this.uploader.Upload(context.Background(), strings.NewReader("abcdefghijklmnopqrstuvwxyz"), Key("key"))
+
+	failure, ok := err.(*ResponseError)
+	if this.So(ok, should.BeTrue) {
+		this.So(failure.Code, should.Equal, "InternalError")
+	}
+	this.So(this.s3.aborted, should.BeTrue)
+}
+
+func (this *UploaderFixture) TestCancelledContextStopsPartUploads() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := this.uploader.uploadParts(ctx, "upload-id", strings.NewReader("abcdefgh"), newBareInput(this.uploader.options), this.uploader.options)
+
+	this.So(err, should.Equal, context.Canceled)
+}
+
+func (this *UploaderFixture) TestMissingKey() {
+	_, err := this.uploader.Upload(context.Background(), strings.NewReader("abc"))
+	this.So(err, should.Equal, ErrKeyMissing)
+}
+
+////////////////////////////////////////////////////////////////
+
+type failingReader struct{ err error }
+
+func (this *failingReader) Read([]byte) (int, error) { return 0, this.err }
+
+type FakeMultipartS3 struct {
+	mutex           sync.Mutex
+	parts           map[string]string
+	failures        map[string]int
+	attempts        map[string]int
+	partCount       int
+	completed       string
+	failureStatus   int
+	failureCode     string
+	completionFails bool
+	aborted         bool
+}
+
+func NewFakeMultipartS3() *FakeMultipartS3 {
+	return &FakeMultipartS3{
+		parts:    make(map[string]string),
+		failures: make(map[string]int),
+		attempts: make(map[string]int),
+
+		failureStatus: http.StatusInternalServerError,
+		failureCode:   "InternalError",
+	}
+}
+
+func (this *FakeMultipartS3) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	query := request.URL.Query()
+	switch {
+	case request.Method == POST && query.Has("uploads"):
+		_, _ = fmt.Fprint(response, "<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>")
+
+	case request.Method == PUT && query.Get("uploadId") == "upload-id":
+		number := query.Get("partNumber")
+		this.attempts[number]++
+		if this.failures[number] > 0 {
+			this.failures[number]--
+			response.WriteHeader(this.failureStatus)
+			_, _ = fmt.Fprint(response, "<Error><Code>"+this.failureCode+"</Code></Error>")
+			return
+		}
+		content, _ := ioutil.ReadAll(request.Body)
+		this.parts[number] = string(content)
+		response.Header().Set("ETag", `"etag-`+number+`"`)
+
+	case request.Method == POST && query.Get("uploadId") == "upload-id" && this.completionFails:
+		response.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprint(response, "<Error><Code>InternalError</Code></Error>")
+
+	case request.Method == POST && query.Get("uploadId") == "upload-id":
+		manifest, _ := ioutil.ReadAll(request.Body)
+		this.partCount = strings.Count(string(manifest), "<Part>")
+		for number := 1; number <= this.partCount; number++ {
+			if !strings.Contains(string(manifest), "&#34;etag-"+strconv.Itoa(number)+"&#34;") {
+				response.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			this.completed += this.parts[strconv.Itoa(number)]
+		}
+		_, _ = fmt.Fprint(response, "<CompleteMultipartUploadResult><ETag>\"complete\"</ETag></CompleteMultipartUploadResult>")
+
+	case request.Method == DELETE && query.Get("uploadId") == "upload-id":
+		this.aborted = true
+		response.WriteHeader(http.StatusNoContent)
+
+	default:
+		response.WriteHeader(http.StatusBadRequest)
+	}
+}